@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"jot/settings"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/gookit/color"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+/* Editors offered by init when they can be found on the path. */
+var knownEditors = []string{"code", "subl", "nvim", "vim", "vi", "nano", "emacs", "micro", "notepad++", "notepad"}
+
+/* Walks the user through choosing a text editor and colors, then saves the
+ * result to the settings file at settingsPath. */
+func runInit(settingsPath string) {
+	reader := bufio.NewReader(os.Stdin)
+
+	// Text editor
+	textEditor := settings.GetTextEditor()
+	fmt.Println("Text editor")
+	candidates := []string{}
+	for _, name := range knownEditors {
+		if editorPath, err := exec.LookPath(name); err == nil {
+			candidates = append(candidates, editorPath)
+		}
+	}
+	for i, candidate := range candidates {
+		fmt.Printf("%3d) %s", i, candidate)
+		fmt.Println()
+	}
+	current := textEditor.TextEditorPath
+	if current == "" {
+		current, _ = settings.FindTextEditor()
+	}
+	answer := prompt(reader, "Pick a number or enter the path of your editor", current)
+	if n, err := parseIndex(answer, len(candidates)); err == nil {
+		answer = candidates[n]
+	}
+	if _, err := exec.LookPath(answer); answer != "" && err != nil {
+		fmt.Printf("Warning: cannot locate '%s', jot will fall back to $VISUAL or $EDITOR.", answer)
+		fmt.Println()
+	}
+	textEditor.TextEditorPath = answer
+	if strings.Contains(answer, "code") || strings.Contains(answer, "subl") {
+		// gui editors return immediately unless told to wait
+		fmt.Println("Tip: this editor usually needs '--wait' (code) or '-w' (subl).")
+	}
+	args := prompt(reader, "Editor arguments, separated by spaces", strings.Join(textEditor.TextEditorArgs, " "))
+	textEditor.TextEditorArgs = strings.Fields(args)
+	settings.SetTextEditor(textEditor)
+
+	// Colors
+	fmt.Println()
+	fmt.Println("Colors, one of: " + strings.Join(colorNames(), ", "))
+	style := settings.GetStyle()
+	style.TitleColor = promptColor(reader, "Title", style.TitleColor)
+	style.DateColor = promptColor(reader, "Date", style.DateColor)
+	style.IdColor = promptColor(reader, "Id", style.IdColor)
+	style.TodoHeadColor = promptColor(reader, "To-do heading", style.TodoHeadColor)
+	style.DoneHeadColor = promptColor(reader, "Done heading", style.DoneHeadColor)
+	style.TodoBulletColor = promptColor(reader, "Bullets", style.TodoBulletColor)
+	style.DoneBulletColor = style.TodoBulletColor
+	settings.SetStyle(style)
+
+	check(settings.Save(settingsPath))
+	fmt.Printf("Settings saved to %s", settingsPath)
+	fmt.Println()
+}
+
+/* Tells the user the text editor can not be found and, when attached to a
+ * terminal, offers to run init. */
+func offerInit(settingsPath string) {
+	fmt.Println("Cannot locate text editor. Check your settings or run 'jot init'.")
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return
+	}
+	reader := bufio.NewReader(os.Stdin)
+	if strings.ToLower(prompt(reader, "Run 'jot init' now? (y/n)", "n")) == "y" {
+		runInit(settingsPath)
+	}
+}
+
+/* Asks question on std out and returns the answer, or def if the answer is empty. */
+func prompt(reader *bufio.Reader, question, def string) string {
+	answer, _ := ask(reader, question, def)
+	return answer
+}
+
+/* Like prompt, but also returns the error reading the answer, io.EOF once
+ * there is no more input. */
+func ask(reader *bufio.Reader, question, def string) (string, error) {
+	if def != "" {
+		fmt.Printf("%s [%s]: ", question, def)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+	answer, err := reader.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return def, err
+	}
+	return answer, err
+}
+
+/* Asks for a color until a known one is given. Once there is no more input
+ * def is kept, even if it is not a known color. */
+func promptColor(reader *bufio.Reader, name, def string) string {
+	for {
+		answer, err := ask(reader, name+" color", def)
+		if err != nil {
+			fmt.Println()
+			return def
+		}
+		if _, ok := color.FgColors[answer]; ok {
+			return answer
+		}
+		fmt.Printf("Unknown color: '%s'", answer)
+		fmt.Println()
+	}
+}
+
+func colorNames() []string {
+	names := []string{}
+	for name := range color.FgColors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/* Parses s as an index into a list of length n. */
+func parseIndex(s string, n int) (int, error) {
+	var i int
+	_, err := fmt.Sscanf(s, "%d", &i)
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 || i >= n || fmt.Sprint(i) != s {
+		return 0, fmt.Errorf("'%s' is not an index in range", s)
+	}
+	return i, nil
+}
@@ -2,17 +2,32 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"jot/display"
 	jot "jot/model"
+	"jot/paths"
 	"jot/settings"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Exit codes
+const (
+	exitError      = 1 // anything not covered below
+	exitUsage      = 2 // bad arguments, as with the flag package
+	exitNotFound   = 3 // no such note
+	exitOutOfRange = 4 // no such item or revision
+	exitAmbiguous  = 5 // a title matches more than one note
+	exitStorage    = 6 // notes can not be read or written
 )
 
 func main() {
@@ -22,20 +37,96 @@ func main() {
 	var fHeaders bool
 	var fPopout bool
 	var fHelp bool
+	var fData string
+	var fList bool
+	var fOlderThan string
+	var fSort string
+	var fRecursive bool
+	var fPriority string
+	var fTag string
+	var fIn string
+	var fArchived bool
+	var fAllNotes bool
 
 	flag.BoolVar(&fTitle, "t", false, "Reference note by title instead of id.")
 	flag.BoolVar(&fAll, "a", false, "Show all notes.")
 	flag.BoolVar(&fHeaders, "h", false, "Show only note headers.")
 	flag.BoolVar(&fPopout, "p", false, "Enter input via text editor.")
 	flag.BoolVar(&fHelp, "help", false, "Show Help.")
-	flag.Parse()
-
-	command := flag.Arg(0)
+	flag.StringVar(&fData, "data", "", "Directory holding notes and settings.")
+	flag.BoolVar(&fList, "list", false, "List instead of acting, e.g. undo --list.")
+	flag.StringVar(&fOlderThan, "older-than", "", "Only act on notes older than this, e.g. 30d, 2w or 12h.")
+	flag.BoolVar(&fRecursive, "r", false, "Check the sub-items of a checked item too.")
+	flag.StringVar(&fPriority, "priority", "", "Priority of an added item, 1 (highest) to 3 or A to C.")
+	flag.StringVar(&fTag, "tag", "", "Only list notes with this tag, e.g. ls --tag work.")
+	flag.StringVar(&fIn, "in", "", "Notebook of a new note, or only list notes in this notebook, e.g. ls --in work.")
+	flag.BoolVar(&fArchived, "archived", false, "List only archived notes, and match archived notes by title.")
+	flag.BoolVar(&fAllNotes, "all", false, "Include archived notes when listing, searching and matching titles.")
+	flag.StringVar(&fSort, "sort", jot.SortCreated, "Order of listed notes: created (oldest first) or modified (most recent first).")
+	parseArgs()
+
+	command := arg(0)
+
+	// how notes are referred to in messages
+	refKind := "id"
+	if fTitle {
+		refKind = "title"
+	}
 
 	// setup paths
-	exePath, err := os.Executable()
+	jotPaths, err := paths.Resolve(fData)
+	check(err)
+	dataPath := jotPaths.DataDir
+
+	// where only reports paths, it does not need any data loaded
+	if command == "where" {
+		// without settings the default backend is used
+		settings.Load(jotPaths.SettingsFile())
+		backend := settings.GetStorage().Backend
+		if backend == "" {
+			backend = jot.BackendJSON
+		}
+		fmt.Printf("Resolved from: %s", jotPaths.Source)
+		fmt.Println()
+		fmt.Printf("Data:          %s", jotPaths.DataDir)
+		fmt.Println()
+		fmt.Printf("Notes:         %s (%s)", jot.StoreLocation(backend, jotPaths.DataDir), backend)
+		fmt.Println()
+		fmt.Printf("Settings:      %s", jotPaths.SettingsFile())
+		fmt.Println()
+		return
+	}
+
+	// first run, create whatever is missing from the defaults
+	createdSettings, err := settings.Bootstrap(jotPaths.SettingsFile())
 	check(err)
-	dataPath := filepath.Join(exePath, "../data/")
+	check(settings.Load(jotPaths.SettingsFile()))
+
+	backend := settings.GetStorage().Backend
+	createdNotes := false
+	if backend == jot.BackendJSON || backend == "" {
+		createdNotes, err = jot.Bootstrap(jotPaths.NotesFile())
+		check(err)
+	}
+	check(jot.Open(backend, jotPaths.DataDir))
+
+	if (createdSettings || createdNotes) && command != "init" {
+		fmt.Printf("Welcome to jot! Default notes and settings were created in %s", jotPaths.DataDir)
+		fmt.Println()
+		fmt.Println("Run 'jot init' to choose your text editor and colors.")
+	}
+
+	// archiving before undo or redo would make them act on the archiving
+	// instead of the last change made by the user
+	if days := settings.GetArchive().AutoArchiveDays; days > 0 && command != "undo" && command != "redo" {
+		archived, err := jot.AutoArchive(time.Duration(days) * 24 * time.Hour)
+		for _, title := range archived {
+			fmt.Printf("Archived note with title: %s, its to-do list has been empty for %d days.", title, days)
+			fmt.Println()
+		}
+		check(err)
+	}
+	jot.ShowArchived(fArchived || fAllNotes || command == "unarchive")
 
 	switch {
 
@@ -44,36 +135,129 @@ func main() {
 		// TODO help
 		flag.PrintDefaults()
 
+	// Interactive setup of the text editor and colors
+	case command == "init":
+		runInit(jotPaths.SettingsFile())
+
 	// List, ls
 	case command == "ls":
+		filter := filterArg(fTag, fIn, fArchived)
+		filtered := fTag != "" || fIn != "" || fArchived
+		checkUsage(jot.SortNotes(nil, fSort))
 		switch {
-		case fAll && fHeaders:
-			display.DisplayAllNoteHeaders()
-		case fAll:
-			display.DisplayAllNotes()
-		case fTitle && fHeaders:
-			display.DisplayNoteHeaderByTitle(os.Args[len(os.Args)-1])
-		case fTitle:
-			display.DisplayNoteByTitle(os.Args[len(os.Args)-1])
-		case flag.Arg(1) != "" && fHeaders:
-			display.DisplayNoteHeaderById(os.Args[len(os.Args)-1])
-		case flag.Arg(1) != "":
-			display.DisplayNoteById(os.Args[len(os.Args)-1])
+		case (fAll || filtered) && fHeaders:
+			check(display.DisplayAllNoteHeaders(fSort, filter))
+		case fAll || filtered:
+			check(display.DisplayAllNotes(fSort, filter))
+		case arg(1) != "" && fHeaders:
+			check(display.DisplayNoteHeaderById(noteId(arg(1), fTitle)))
+		case arg(1) != "":
+			check(display.DisplayNoteById(noteId(arg(1), fTitle)))
 		default:
-			// TODO: What should default ls do?
-			display.DisplayLastNote()
+			check(display.DisplayPinnedAndRecent(fHeaders))
 		}
+
 	// Search keywords
 	case command == "search":
+		filter := filterArg(fTag, fIn, fArchived)
 		if fHeaders {
-			display.DisplayNotesHeadersBySearch(strings.Join(flag.Args()[1:], " "))
+			check(display.DisplayNotesHeadersBySearch(strings.Join(args[1:], " "), filter))
+		} else {
+			check(display.DisplayNotesBySearch(strings.Join(args[1:], " "), filter))
+		}
+
+	// Move a note to a notebook, / being none
+	case command == "mv":
+		id := noteId(arg(1), fTitle)
+		if len(args) < 3 {
+			usage("Missing notebook, use / to take the note out of its notebook.")
+		}
+		title, err := jot.MoveNote(id, arg(2))
+		check(err)
+		if notebook := jot.CleanNotebook(arg(2)); notebook != "" {
+			fmt.Printf("Moved note with title: %s to notebook: %s", title, notebook)
 		} else {
-			display.DisplayNotesBySearch(strings.Join(flag.Args()[1:], " "))
+			fmt.Printf("Took note with title: %s out of its notebook", title)
 		}
+		fmt.Println()
+
+	// Pin a note to the top of ls, or unpin it
+	case command == "pin" || command == "unpin":
+		id := noteId(arg(1), fTitle)
+		title, err := jot.PinNote(id, command == "pin")
+		check(err)
+		if command == "pin" {
+			fmt.Printf("Pinned note with title: %s", title)
+		} else {
+			fmt.Printf("Unpinned note with title: %s", title)
+		}
+		fmt.Println()
+
+	// Hide a note from listing, search and titles, or show it again
+	case command == "archive" || command == "unarchive":
+		id := noteId(arg(1), fTitle)
+		title, err := jot.ArchiveNote(id, command == "archive")
+		check(err)
+		if command == "archive" {
+			fmt.Printf("Archived note with title: %s", title)
+		} else {
+			fmt.Printf("Took note with title: %s out of the archive", title)
+		}
+		fmt.Println()
+
+	// Tree of notebooks
+	case command == "notebooks":
+		notebooks, err := jot.GetNotebooks()
+		check(err)
+		display.DisplayNotebooks(notebooks)
+
+	// Notes linking to a note
+	case command == "backlinks":
+		check(display.DisplayBacklinks(noteId(arg(1), fTitle)))
+
+	// Links of a note, or every broken link
+	case command == "links":
+		if arg(1) == "" {
+			broken, err := jot.GetBrokenLinks()
+			check(err)
+			display.DisplayBrokenLinks(broken)
+			return
+		}
+		links, err := jot.GetLinks(noteId(arg(1), fTitle))
+		check(err)
+		display.DisplayLinks(links)
+
+	// List tags and how many notes have them
+	case command == "tags":
+		tags, err := jot.GetTagCounts()
+		check(err)
+		display.DisplayTags(tags)
+
+	// Add and remove tags, e.g. tag id +work -home
+	case command == "tag":
+		id := noteId(arg(1), fTitle)
+		var add, remove []string
+		for _, change := range args[2:] {
+			if change == "" || (change[0] != '+' && change[0] != '-') {
+				usage("'%s' is not a tag change, use +tag to add a tag and -tag to remove one.", change)
+			}
+			tag, err := jot.ParseTag(change[1:])
+			checkUsage(err)
+			if change[0] == '+' {
+				add = append(add, tag)
+			} else {
+				remove = append(remove, tag)
+			}
+		}
+		if len(add)+len(remove) == 0 {
+			usage("Missing tags, use +tag to add a tag and -tag to remove one.")
+		}
+		check(jot.TagNote(id, add, remove))
+		check(display.DisplayNoteHeaderById(id))
 
 	// New Note
 	case command == "new":
-		title := flag.Arg(1)
+		title := arg(1)
 
 		var note string
 		success := true
@@ -84,289 +268,539 @@ func main() {
 			note, success = readNoteFromTextEditor(dataPath, title)
 		}
 
-		if success {
-			newNoteId := jot.NewNote(note)
-			fmt.Printf("New note created with id: %s", newNoteId)
-			fmt.Println()
-			// Here we could get away with "DisplayLastNote" but its probably more
-			// reliable to display by ID.
-			display.DisplayNoteById(newNoteId)
-		} else {
-			fmt.Printf("Cannot locate text editor. Check your settings.")
-			fmt.Println()
+		if !success {
+			offerInit(jotPaths.SettingsFile())
+			os.Exit(exitError)
 		}
+		newNoteId, err := jot.NewNote(note, fIn)
+		check(err)
+		fmt.Printf("New note created with id: %s", newNoteId)
+		fmt.Println()
+		// Here we could get away with "DisplayLastNote" but its probably more
+		// reliable to display by ID.
+		check(display.DisplayNoteById(newNoteId))
 
 	// Delete a note
 	case command == "rm" || command == "del":
-		switch {
-		case fTitle:
-			title := flag.Arg(1)
-			id, found := jot.DeleteNoteByTitle(title)
-			if found {
-				fmt.Printf("Note deleted with id: %s", id)
-				fmt.Println()
-			} else {
-				fmt.Printf("No note found with title: %s", title)
-				fmt.Println()
-			}
-
-		default:
-			id := flag.Arg(1)
-			title, found := jot.DeleteNote(id)
-			if found {
-				fmt.Printf("Note deleted with title: %s", title)
-				fmt.Println()
-			} else {
-				fmt.Printf("No note found with id: %s", id)
-				fmt.Println()
-			}
-		}
+		id := noteId(arg(1), fTitle)
+		confirmUnlink(id)
+		title, err := jot.DeleteNote(id)
+		check(err)
+		fmt.Printf("Note moved to trash with title: %s, id: %s", title, id)
+		fmt.Println()
 
 	// Checking an item on the to-do / check list
 	case command == "check":
-		nString := flag.Arg(2)
-		n, err := strconv.Atoi(nString)
+		ref := itemArg(arg(2))
+		id := noteId(arg(1), fTitle)
+		item, err := jot.CheckItem(id, ref, fRecursive)
+		check(err)
+		fmt.Printf("Checked item: '%s' from note with %s: '%s'", item, refKind, arg(1))
+		fmt.Println()
+		check(display.DisplayNoteById(id))
 
-		if err != nil || n < 0 {
-			fmt.Printf("'%v' is not an non-negative integer.", nString)
-			return
+	// Unchecking an item on the to-do / check list
+	case command == "uncheck":
+		ref := itemArg(arg(2))
+		id := noteId(arg(1), fTitle)
+		item, err := jot.UnCheckItem(id, ref)
+		check(err)
+		fmt.Printf("Unchecked item: '%s' from note with %s: '%s'", item, refKind, arg(1))
+		fmt.Println()
+		check(display.DisplayNoteById(id))
+
+	// Add an item to the to-do / check list
+	case command == "add":
+		item := arg(2)
+		priority := priorityArg(fPriority)
+		id := noteId(arg(1), fTitle)
+		handle, err := jot.AddItem(id, item, priority)
+		check(err)
+		fmt.Printf("Added item: '%s' with handle: %s to note with %s: '%s'", item, handle, refKind, arg(1))
+		fmt.Println()
+		check(display.DisplayNoteById(id))
+
+	// Raise the priority of a to-do item by one, or set it
+	case command == "bump":
+		ref := itemArg(arg(2))
+		id := noteId(arg(1), fTitle)
+		var item string
+		var err error
+		priority := 0
+		if arg(3) == "" {
+			item, priority, err = jot.BumpItemPriority(id, ref)
+		} else {
+			priority = priorityArg(arg(3))
+			item, err = jot.SetItemPriority(id, ref, priority)
+		}
+		check(err)
+		if priority == 0 {
+			fmt.Printf("Cleared the priority of item: '%s' from note with %s: '%s'", item, refKind, arg(1))
+		} else {
+			fmt.Printf("Set the priority of item: '%s' to %s on note with %s: '%s'", item, jot.PriorityString(priority), refKind, arg(1))
+		}
+		fmt.Println()
+		check(display.DisplayNoteById(id))
+
+	// Reorder an item within its list
+	case command == "move":
+		ref := itemArg(arg(2))
+		to := arg(3)
+		if to == "" {
+			usage("Missing the item number or address to move the item to.")
 		}
+		_, err := jot.ParseAddress(to)
+		checkUsage(err)
+		id := noteId(arg(1), fTitle)
+		item, err := jot.MoveItem(id, ref, to)
+		check(err)
+		fmt.Printf("Moved item: '%s' to %s on note with %s: '%s'", item, to, refKind, arg(1))
+		fmt.Println()
+		check(display.DisplayNoteById(id))
+
+	// Move an item to another note
+	case command == "transfer":
+		ref := itemArg(arg(2))
+		src := noteId(arg(1), fTitle)
+		dst := noteId(arg(3), fTitle)
+		item, err := jot.TransferItem(src, ref, dst)
+		check(err)
+		fmt.Printf("Transferred item: '%s' from note with %s: '%s' to note with %s: '%s'", item, refKind, arg(1), refKind, arg(3))
+		fmt.Println()
+		check(display.DisplayNoteById(dst))
 
-		switch {
-		// Reference note by title
-		case fTitle:
-			title := flag.Arg(1)
-			item, success := jot.CheckItemByNoteTitle(title, n)
-
-			if success {
-				fmt.Printf("Checked item: '%s' from note with title: '%s'", item, title)
-				fmt.Println()
-				display.DisplayNoteByTitle(title)
-			} else {
-				fmt.Printf("Cannot find item number: '%d' from note with title: '%s'", n, title)
-				fmt.Println()
-			}
+	// Remove an item from the to-do / check list
+	case command == "scratch":
+		ref := itemArg(arg(2))
+		id := noteId(arg(1), fTitle)
+		item, err := jot.RemoveItem(id, ref)
+		check(err)
+		fmt.Printf("Removed item: '%s' from note with %s: '%s'", item, refKind, arg(1))
+		fmt.Println()
+		check(display.DisplayNoteById(id))
 
-		// No options
-		default:
-			id := flag.Arg(1)
-			item, success := jot.CheckItem(id, n)
+	// edit note
+	case command == "edit":
+		id := noteId(arg(1), fTitle)
+		oldText, err := jot.GetNoteString(id)
+		check(err)
 
-			if success {
-				fmt.Printf("Checked item: '%s' from note with id: '%s'", item, id)
-				fmt.Println()
-				display.DisplayNoteById(id)
-			} else {
-				fmt.Printf("Cannot find item number: '%d' from note with id: '%s'", n, id)
-				fmt.Println()
+		// After getting user input, edit the note
+		written, success := readNoteFromTextEditor(dataPath, oldText)
+		if !success {
+			offerInit(jotPaths.SettingsFile())
+			os.Exit(exitError)
+		}
+		err = jot.EditNote(id, oldText, written)
+		if errors.Is(err, jot.ErrEditConflict) {
+			// keep what was written so it can be redone by hand
+			saved := filepath.Join(dataPath, "edit-"+id+".txt")
+			if ioutil.WriteFile(saved, []byte(written), 0644) == nil {
+				err = fmt.Errorf("%w. Nothing was overwritten, your text was saved to %s", err, saved)
 			}
 		}
+		check(err)
+		fmt.Println("Success, note changed:")
+		check(display.DisplayNoteById(id))
 
-	// Unchecking an item on the to-do / check list
-	case command == "uncheck":
-		nString := flag.Arg(2)
-		n, err := strconv.Atoi(nString)
-
-		if err != nil || n < 0 {
-			fmt.Printf("'%v' is not an non-negative integer.", nString)
-			return
-		}
+	// amend, edit a list item
+	case command == "amend":
+		ref := itemArg(arg(2))
+		id := noteId(arg(1), fTitle)
+		newItem := arg(3)
+		check(jot.EditListItem(id, ref, newItem))
+		fmt.Println("Success: ")
+		check(display.DisplayNoteById(id))
+
+	// Open items with a due date across all notes
+	case command == "agenda":
+		agenda, err := jot.GetAgenda(time.Now())
+		check(err)
+		display.DisplayAgenda(agenda)
+
+	// Trash: list, restore or permanently delete deleted notes
+	case command == "trash":
+		switch arg(1) {
+		case "ls", "":
+			check(display.DisplayTrash())
+
+		case "restore":
+			id, err := jot.ResolveId(arg(2))
+			check(err)
+			title, err := jot.RestoreNote(id)
+			check(err)
+			fmt.Printf("Restored note with title: %s", title)
+			fmt.Println()
+			check(display.DisplayNoteById(id))
 
-		switch {
-		// Reference note by title
-		case fTitle:
-			title := flag.Arg(1)
-			item, success := jot.UnCheckItemByNoteTitle(title, n)
-
-			if success {
-				fmt.Printf("Unchecked item: '%s' from note with title: '%s'", item, title)
-				fmt.Println()
-				display.DisplayNoteByTitle(title)
-			} else {
-				fmt.Printf("Cannot find item number: '%d' from note with title: '%s'", n, title)
-				fmt.Println()
+		case "empty":
+			olderThan, err := parseAge(fOlderThan)
+			if err != nil {
+				usage("%v", err)
 			}
+			n, err := jot.EmptyTrash(olderThan)
+			fmt.Printf("Permanently deleted %d notes.", n)
+			fmt.Println()
+			check(err)
 
-		// No options
 		default:
-			id := flag.Arg(1)
-			item, success := jot.UnCheckItem(id, n)
-
-			if success {
-				fmt.Printf("Unchecked item: '%s' from note with id: '%s'", item, id)
-				fmt.Println()
-				display.DisplayNoteById(id)
-			} else {
-				fmt.Printf("Cannot find item number: '%d' from note with id: '%s'", n, id)
-				fmt.Println()
-			}
+			usage("Unrecognized trash command: '%s'. Use ls, restore or empty.", arg(1))
 		}
 
-	// Add an item to the to-do / check list
-	case command == "add":
-		item := flag.Arg(2)
+	// List the revisions of a note
+	case command == "history":
+		check(display.DisplayHistory(noteId(arg(1), fTitle)))
 
-		switch {
-		// Reference note by title
-		case fTitle:
-			title := flag.Arg(1)
-			success := jot.AddItemByNoteTitle(title, item)
-
-			if success {
-				fmt.Printf("Added item: '%s' to note with title: '%s'", item, title)
-				fmt.Println()
-				display.DisplayNoteByTitle(title)
-			} else {
-				fmt.Printf("Cannot find note with title: '%s'", title)
-				fmt.Println()
-			}
+	// Show what changed between two revisions of a note, by default the last
+	// revision and the current version
+	case command == "diff":
+		id := noteId(arg(1), fTitle)
+		revisions, err := jot.GetRevisions(id)
+		check(err)
+		if len(revisions) == 0 {
+			fmt.Println("This note has never been edited.")
+			return
+		}
 
-		// No options
-		default:
-			id := flag.Arg(1)
-			success := jot.AddItem(id, item)
+		from, to := len(revisions)-1, len(revisions)
+		if arg(2) != "" {
+			from = indexArg(arg(2))
+		}
+		if arg(3) != "" {
+			to = indexArg(arg(3))
+		}
+		fromText, err := jot.GetRevisionText(id, from)
+		check(err)
+		toText, err := jot.GetRevisionText(id, to)
+		check(err)
+		display.DisplayDiff(jot.Diff(fromText, toText))
+
+	// Replace a note with one of its revisions
+	case command == "revert":
+		id := noteId(arg(1), fTitle)
+		rev := indexArg(arg(2))
+		check(jot.RevertNote(id, rev))
+		fmt.Printf("Reverted to revision %d:", rev)
+		fmt.Println()
+		check(display.DisplayNoteById(id))
+
+	// Undo the last n mutations, or list them
+	case command == "undo":
+		if fList {
+			journal, err := jot.GetJournal()
+			check(err)
+			display.DisplayJournal(journal)
+			return
+		}
 
-			if success {
-				fmt.Printf("Checked item: '%s' from note with id: '%s'", item, id)
-				fmt.Println()
-				display.DisplayNoteById(id)
-			} else {
-				fmt.Printf("Cannot find note with id: '%s'", id)
-				fmt.Println()
-			}
+		undone, err := jot.Undo(countArg(arg(1)))
+		if len(undone) == 0 && err == nil {
+			fmt.Println("Nothing to undo.")
 		}
+		for _, op := range undone {
+			fmt.Printf("Undid: %s", display.OperationString(op))
+			fmt.Println()
+		}
+		check(err)
 
-	// Remove an item from the to-do / check list
-	case command == "scratch":
-		nString := flag.Arg(2)
-		n, err := strconv.Atoi(nString)
+	// Redo the last n undone mutations
+	case command == "redo":
+		redone, err := jot.Redo(countArg(arg(1)))
+		if len(redone) == 0 && err == nil {
+			fmt.Println("Nothing to redo.")
+		}
+		for _, op := range redone {
+			fmt.Printf("Redid: %s", display.OperationString(op))
+			fmt.Println()
+		}
+		check(err)
 
-		if err != nil || n < 0 {
-			fmt.Printf("'%v' is not an non-negative integer.", nString)
+	// Copy all notes into another storage backend and switch to it
+	case command == "migrate-storage":
+		to := arg(1)
+		if to == "" {
+			usage("Missing the backend to migrate to, one of json, markdown or sqlite.")
+		}
+		if to == backend || (to == jot.BackendJSON && backend == "") {
+			fmt.Printf("Notes are already stored with the '%s' backend.", to)
+			fmt.Println()
 			return
 		}
 
-		switch {
-		// Reference note by title
-		case fTitle:
-			title := flag.Arg(1)
-			item, success := jot.RemoveItemByNoteTitle(title, n)
-
-			if success {
-				fmt.Printf("Removed item: '%s' from note with title: '%s'", item, title)
-				fmt.Println()
-				display.DisplayNoteByTitle(title)
-			} else {
-				fmt.Printf("Cannot find item number: '%d' from note with title: '%s'", n, title)
-				fmt.Println()
-			}
+		n, backup, err := jot.MigrateStorage(to, dataPath)
+		if err != nil {
+			fail(fmt.Errorf("cannot migrate notes to '%s': %w", to, err))
+		}
 
-		// No options
-		default:
-			id := flag.Arg(1)
-			item, success := jot.RemoveItem(id, n)
+		storage := settings.GetStorage()
+		storage.Backend = to
+		settings.SetStorage(storage)
+		check(settings.Save(jotPaths.SettingsFile()))
+		fmt.Printf("Copied %d notes to %s, jot now uses the '%s' backend.", n, jot.StoreLocation(to, dataPath), to)
+		fmt.Println()
+		if backup != "" {
+			fmt.Printf("What was at %s before was moved to %s.", jot.StoreLocation(to, dataPath), backup)
+			fmt.Println()
+		}
+		fmt.Printf("The old notes at %s were left in place.", jot.StoreLocation(backend, dataPath))
+		fmt.Println()
 
-			if success {
-				fmt.Printf("Removed item: '%s' from note with id: '%s'", item, id)
-				fmt.Println()
-				display.DisplayNoteById(id)
-			} else {
-				fmt.Printf("Cannot find item number: '%d' from note with id: '%s'", n, id)
-				fmt.Println()
-			}
+	default:
+		usage("Unrecognized command: '%s'. use 'jot -help' to see a list of available commands.", command)
+	}
+}
+
+/*************Helper Functions*************/
+/* Exits with a message and exit code matching err, if there is an error. */
+func check(err error) {
+	if err != nil {
+		fail(err)
+	}
+}
+
+/* Prints a message for err to std err and exits with the matching exit code. */
+func fail(err error) {
+	message := err.Error()
+	message = strings.ToUpper(message[:1]) + message[1:] + "."
+	code := exitError
+	switch {
+	case errors.Is(err, jot.ErrNoteNotFound):
+		code = exitNotFound
+	case errors.Is(err, jot.ErrItemOutOfRange), errors.Is(err, jot.ErrItemNotFound):
+		code = exitOutOfRange
+	case errors.Is(err, jot.ErrAmbiguousTitle):
+		code = exitAmbiguous
+		var ambiguous *jot.AmbiguousTitleError
+		if errors.As(err, &ambiguous) {
+			message = fmt.Sprintf("More than one note matches the title '%s', refer to the note by id:", ambiguous.Title)
+			message += candidates(ambiguous.Matches)
+		}
+	case errors.Is(err, jot.ErrAmbiguousId):
+		code = exitAmbiguous
+		var ambiguous *jot.AmbiguousIdError
+		if errors.As(err, &ambiguous) {
+			message = fmt.Sprintf("More than one note has an id starting with '%s', use a longer prefix:", ambiguous.Prefix)
+			message += candidates(ambiguous.Matches)
 		}
+	case errors.Is(err, jot.ErrStorage):
+		code = exitStorage
+		message += " Run 'jot where' to see which files are used."
+	}
+	fmt.Fprintln(os.Stderr, message)
+	os.Exit(code)
+}
 
-	// edit note
-	case command == "edit":
-		switch {
-		case fTitle:
-			title := flag.Arg(1)
-			id, found := jot.GetIdFromTitle(title)
-			oldText, found := jot.GetNoteString(id)
-
-			// After getting user input, edit the note
-			if found {
-				written, success := readNoteFromTextEditor(dataPath, oldText)
-				if success {
-					if jot.EditNote(id, written) {
-						fmt.Println("Success, note changed:")
-						display.DisplayNoteById(id)
-					} else {
-						fmt.Println("Failure, note not changed.")
-					}
-				} else {
-					fmt.Printf("Cannot locate text editor. Check your settings.")
-					fmt.Println()
-				}
-			} else {
-				fmt.Printf("No note found with title: %s", title)
-				fmt.Println()
-			}
+/* Lists notes one per line for an error message. */
+func candidates(notes []jot.Note) string {
+	s := ""
+	for _, note := range notes {
+		s += fmt.Sprintf("\n  %s  %s", note.Id, note.Title)
+	}
+	return s
+}
 
-		default:
-			id := flag.Arg(1)
-			oldText, found := jot.GetNoteString(id)
-
-			// After getting user input, edit the note
-			if found {
-				written, success := readNoteFromTextEditor(dataPath, oldText)
-				if success {
-					if jot.EditNote(id, written) {
-						fmt.Println("Success, note changed:")
-						display.DisplayNoteById(id)
-					} else {
-						fmt.Println("Failure, note not changed.")
-					}
-				} else {
-					fmt.Printf("Cannot locate text editor. Check your settings.")
-					fmt.Println()
-				}
-			} else {
-				fmt.Printf("No note found with id: %s", id)
-				fmt.Println()
-			}
+/* Exits as if err was a usage error, if there is an error. */
+func checkUsage(err error) {
+	if err != nil {
+		usage("%s.", strings.ToUpper(err.Error()[:1])+err.Error()[1:])
+	}
+}
+
+/* Prints a message about bad arguments to std err and exits. */
+func usage(format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, format, a...)
+	fmt.Fprintln(os.Stderr)
+	os.Exit(exitUsage)
+}
+
+/* Positional arguments, the command first. */
+var args []string
+
+/* Returns the ith positional argument or "" if there are not that many. */
+func arg(i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
+
+/* Parses the command line, allowing flags after the command as well as
+ * before it, e.g. `jot undo --list`. Everything after "--" is positional, as
+ * are tags to remove given to tag, e.g. `jot tag id -home`, unless they are
+ * flags. */
+func parseArgs() {
+	rest := os.Args[1:]
+	for {
+		if len(args) > 0 && args[0] == "tag" && len(rest) > 0 && isTagRemoval(rest[0]) {
+			args = append(args, rest[0])
+			rest = rest[1:]
+			continue
+		}
+		before := len(rest)
+		flag.CommandLine.Parse(rest)
+		rest = flag.Args()
+		consumed := before - len(rest)
+		if consumed > 0 && os.Args[len(os.Args)-len(rest)-1] == "--" {
+			args = append(args, rest...)
+			return
+		}
+		if len(rest) == 0 {
+			return
 		}
+		args = append(args, rest[0])
+		rest = rest[1:]
+	}
+}
 
-	// amend, edit a list item
-	case command == "amend":
-		n, err := strconv.Atoi(flag.Arg(2))
+/* Returns whether s is a tag to remove rather than a flag, i.e. one dash and
+ * no flag of that name. */
+func isTagRemoval(s string) bool {
+	return len(s) > 1 && s[0] == '-' && s[1] != '-' && flag.Lookup(strings.SplitN(s[1:], "=", 2)[0]) == nil
+}
+
+/* Returns the id of the note referenced by ref, which is a title if byTitle
+ * is set and an id or id prefix otherwise. Exits if there is no such note. When a title
+ * matches several notes the user picks one on a terminal, otherwise jot exits
+ * listing the matches. */
+func noteId(ref string, byTitle bool) string {
+	if ref == "" {
+		if byTitle {
+			usage("Missing note title.")
+		}
+		usage("Missing note id.")
+	}
+	if byTitle {
+		id, err := jot.GetIdFromTitle(ref)
+		var ambiguous *jot.AmbiguousTitleError
+		if errors.As(err, &ambiguous) && terminal.IsTerminal(int(os.Stdin.Fd())) {
+			return pickNote(ambiguous.Matches)
+		}
 		check(err)
-		var id string
-		newItem := flag.Arg(3)
+		return id
+	}
+	id, err := jot.ResolveId(ref)
+	check(err)
+	_, err = jot.GetNoteById(id)
+	check(err)
+	return id
+}
 
-		switch {
-		case fTitle:
-			title := os.Args[len(os.Args)-3]
-			var success bool
-			id, success = jot.GetIdFromTitle(title)
-			if success && jot.EditListItem(id, n, newItem) {
-				fmt.Println("Success: ")
-				display.DisplayNoteById(id)
-			} else {
-				fmt.Println("Failure: Cannot amend item.")
-			}
+/* Warns that the links of other notes to the note with id will break if it
+ * is deleted. On a terminal the user is asked whether to go on, otherwise
+ * the warning goes to std err. */
+func confirmUnlink(id string) {
+	linking, err := jot.GetBacklinks(id)
+	check(err)
+	if len(linking.Notes) == 0 {
+		return
+	}
+	warning := fmt.Sprintf("%d notes link to this note, their links will break:", len(linking.Notes))
+	if len(linking.Notes) == 1 {
+		warning = "A note links to this note, its links will break:"
+	}
+	warning += candidates(linking.Notes)
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintln(os.Stderr, "Warning: "+warning)
+		return
+	}
+	fmt.Println(warning)
+	answer := prompt(bufio.NewReader(os.Stdin), "Move it to the trash anyway? (y/n)", "n")
+	if !strings.HasPrefix(strings.ToLower(answer), "y") {
+		fmt.Println("Nothing was deleted.")
+		os.Exit(exitError)
+	}
+}
 
-		default:
-			id = os.Args[len(os.Args)-3]
-			if jot.EditListItem(id, n, newItem) {
-				fmt.Println("Success: ")
-				display.DisplayNoteById(id)
-			} else {
-				fmt.Println("Failure: Cannot amend item.")
-			}
+/* Asks the user which of notes they mean and returns its id. */
+func pickNote(notes []jot.Note) string {
+	fmt.Println("More than one note matches, which one do you mean?")
+	for i, note := range notes {
+		fmt.Printf("%d) %s  %s", i, note.Id, note.Title)
+		fmt.Println()
+	}
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		answer := prompt(reader, "Note", "")
+		i, err := parseIndex(answer, len(notes))
+		if err == nil {
+			return notes[i].Id
 		}
+		if answer == "" {
+			os.Exit(exitError)
+		}
+		fmt.Println(err)
+	}
+}
 
-	default:
-		fmt.Printf("Unrecognized command: '%s'. use 'jot -help' to see a list of available commands.", command)
-		fmt.Println()
+/* Returns a reference to a checklist item, its handle or index. Exits if
+ * there is none. */
+func itemArg(s string) string {
+	if s == "" {
+		usage("Missing item handle or number.")
 	}
+	return s
 }
 
-/*************Helper Functions*************/
-func check(err error) {
-	if err != nil {
-		panic(err.Error())
+/* Parses a priority, empty means none. Exits if it is not one. */
+func priorityArg(s string) int {
+	if s == "" {
+		return 0
 	}
+	priority, err := jot.ParsePriority(s)
+	checkUsage(err)
+	return priority
+}
+
+/* Returns the filter of listed notes given by flags. Exits if a flag is
+ * invalid. */
+func filterArg(tag, notebook string, archived bool) jot.Filter {
+	filter := jot.Filter{Notebook: notebook, Archived: archived}
+	if tag != "" {
+		var err error
+		filter.Tag, err = jot.ParseTag(tag)
+		checkUsage(err)
+	}
+	return filter
+}
+
+/* Parses a revision number. Exits if it is not one. */
+func indexArg(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		usage("'%v' is not a non-negative integer.", s)
+	}
+	return n
+}
+
+/* Parses an age such as 30d, 2w or 12h. Empty means 0. */
+func parseAge(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	units := map[byte]time.Duration{
+		'm': time.Minute,
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+	}
+	unit, ok := units[s[len(s)-1]]
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if !ok || err != nil || n < 0 {
+		return 0, fmt.Errorf("'%s' is not an age such as 30d, 2w or 12h.", s)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+/* Parses an optional count, e.g. for undo. Empty means 1. */
+func countArg(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		usage("'%v' is not a positive integer.", s)
+	}
+	return n
 }
 
 func readNoteFromConsole(title string) string {
@@ -398,14 +832,20 @@ func readNoteFromTextEditor(path, seedText string) (written string, success bool
 	file.Close()
 
 	// open in text editor
-	success = true
-	editorSettings := settings.GetTextEditor()
-	editorPath := editorSettings.TextEditorPath
-	editorArgs := editorSettings.TextEditorArgs
+	editorPath, success := settings.FindTextEditor()
+	if !success {
+		os.Remove(fp)
+		return "", false
+	}
+	editorArgs := settings.GetTextEditor().TextEditorArgs
 
 	// prepend filepath into args
 	editorArgs = append([]string{fp}, editorArgs...)
 	cmd := exec.Command(editorPath, editorArgs...)
+	// terminal editors need the console
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
 	err = cmd.Run()
 	if err != nil {
 		success = false
@@ -6,6 +6,7 @@ import (
 	"jot/settings"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 
@@ -26,12 +27,22 @@ func displayNote(note jot.Note) {
 	titleStyle := color.New(color.FgColors[style.TitleColor], color.BgColors[style.TitleBackground])
 	dateStyle := color.New(color.FgColors[style.DateColor], color.BgColors[style.DateBackground])
 	idStyle := color.New(color.FgColors[style.IdColor], color.BgColors[style.IdBackground])
+	idPrefixStyle := color.New(color.FgColors[style.IdColor], color.BgColors[style.IdBackground], color.OpBold, color.OpUnderscore)
+	tagStyle := color.New(color.FgColors[style.TagColor], color.BgColors[style.TagBackground])
 	todoHeadStyle := color.New(color.FgColors[style.TodoHeadColor], color.BgColors[style.TodoHeadBackground])
-	todoBulletStyle := color.New(color.FgColors[style.TodoBulletColor], color.BgColors[style.TodoBulletBackground])
-	todoItemStyle := color.New(color.FgColors[style.TodoItemColor], color.BgColors[style.TodoItemBackground])
 	doneHeadStyle := color.New(color.FgColors[style.DoneHeadColor], color.BgColors[style.DoneHeadBackground])
-	doneBulletStyle := color.New(color.FgColors[style.DoneBulletColor], color.BgColors[style.DoneBulletBackground])
-	doneItemStyle := color.New(color.FgColors[style.DoneItemColor], color.BgColors[style.DoneItemBackground])
+	styles := itemStyles{
+		todoBullet: color.New(color.FgColors[style.TodoBulletColor], color.BgColors[style.TodoBulletBackground]),
+		todoItem:   color.New(color.FgColors[style.TodoItemColor], color.BgColors[style.TodoItemBackground]),
+		doneBullet: color.New(color.FgColors[style.DoneBulletColor], color.BgColors[style.DoneBulletBackground]),
+		doneItem:   color.New(color.FgColors[style.DoneItemColor], color.BgColors[style.DoneItemBackground]),
+		date:       dateStyle,
+		overdue:    color.New(color.FgColors[style.OverdueColor], color.BgColors[style.OverdueBackground]),
+		link:       color.New(color.FgColors[style.LinkColor], color.BgColors[style.LinkBackground]),
+		brokenLink: color.New(color.FgColors[style.BrokenLinkColor], color.BgColors[style.BrokenLinkBackground]),
+		priorities: priorityStyles(style),
+		byPriority: style.SortByPriority,
+	}
 
 	indent := ""
 	for i := style.IndentWidth; i > 0; i-- {
@@ -40,21 +51,21 @@ func displayNote(note jot.Note) {
 
 	// Header
 	fmt.Println()
-	time := time.Unix(int64(note.Time), 0).Format("Jan 2 3:04 2006")
-	titleStyle.Printf(note.Title)
-	fmt.Println()
-	fmt.Print("Taken: ")
-	dateStyle.Printf("%v", time)
+	titleStyle.Print(note.Title)
+	printPinned(note)
 	fmt.Println()
+	printDates(note, dateStyle)
 	fmt.Print("ID: ")
-	idStyle.Printf(note.Id)
+	printId(note.Id, idPrefixStyle, idStyle)
+	printNotebook(note)
+	printTags(note, tagStyle)
 
 	// Lines
 	if len(note.Lines) != 0 {
 		fmt.Println()
 	}
 	for i := 0; i < len(note.Lines); i++ {
-		SplitPrintln(indent, note.Lines[i], defaultStyle, contentStyle)
+		splitPrintSegments(indent, defaultStyle, linkSegments(note.Lines[i], contentStyle, styles)...)
 	}
 
 	// 'to-do'
@@ -64,10 +75,7 @@ func displayNote(note jot.Note) {
 		todoHeadStyle.Printf("To-do:")
 		fmt.Println()
 	}
-	for i := 0; i < len(note.Todo); i++ {
-		prefix := fmt.Sprintf(indent+"%3d) ", i)
-		SplitPrintln(prefix, note.Todo[i], todoBulletStyle, todoItemStyle)
-	}
+	displayItems(note.Todo, "", nil, indent, styles)
 
 	// Done
 	if len(note.Done) != 0 {
@@ -76,14 +84,110 @@ func displayNote(note jot.Note) {
 		doneHeadStyle.Printf("Done:")
 		fmt.Println()
 	}
-	for i := 0; i < len(note.Done); i++ {
-		prefix := fmt.Sprintf(indent+"%3d) ", i)
-		SplitPrintln(prefix, note.Done[i], doneBulletStyle, doneItemStyle)
-	}
+	displayItems(note.Done, jot.DonePrefix, nil, indent, styles)
 
 	fmt.Println()
 }
 
+/* Styles used to display checklist items. */
+type itemStyles struct {
+	todoBullet, todoItem color.Style
+	doneBullet, doneItem color.Style
+	date, overdue        color.Style
+	link, brokenLink     color.Style
+	priorities           []color.Style // by priority, none first
+	byPriority           bool          // list items by priority
+}
+
+/* Returns the styles of the priorities, indexed by priority. */
+func priorityStyles(style settings.Style) []color.Style {
+	return []color.Style{
+		color.New(color.FgColors["default"], color.BgColors["default"]),
+		color.New(color.FgColors[style.Priority1Color], color.BgColors[style.Priority1Background]),
+		color.New(color.FgColors[style.Priority2Color], color.BgColors[style.Priority2Background]),
+		color.New(color.FgColors[style.Priority3Color], color.BgColors[style.Priority3Background]),
+	}
+}
+
+/* Displays items as a tree, sub-items indented under their parent and
+ * numbered by address, e.g. 2.1 for the first sub-item of item 2, each
+ * address starting with list, the prefix of the list they are in. parent is
+ * the path of the items' parent, nil at the top. Items keep their address
+ * when they are listed by priority. */
+func displayItems(items []jot.Item, list string, parent []int, indent string, styles itemStyles) {
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	if styles.byPriority {
+		sort.SliceStable(order, func(a, b int) bool {
+			return priorityRank(items[order[a]].Priority) < priorityRank(items[order[b]].Priority)
+		})
+	}
+
+	for _, i := range order {
+		item := items[i]
+		path := append(append([]int{}, parent...), i)
+		bulletStyle, itemStyle := styles.todoBullet, styles.todoItem
+		date := itemDate("added", item.CreatedAt)
+		if item.Checked {
+			bulletStyle, itemStyle = styles.doneBullet, styles.doneItem
+			date = itemDate("done", item.CompletedAt)
+		}
+
+		prefix := fmt.Sprintf("%s%s%3s) %s ", indent, strings.Repeat("    ", len(parent)), list+jot.AddressString(path), item.Handle)
+		segments := []segment{}
+		if item.Priority != 0 {
+			segments = append(segments, segment{jot.PriorityString(item.Priority) + " ", styles.priorities[item.Priority]})
+		}
+		segments = append(segments, linkSegments(item.Text, itemStyle, styles)...)
+		if due, ok := item.DueDate(time.Local); ok {
+			dueStyle := styles.date
+			if !item.Checked && isOverdue(due) {
+				dueStyle = styles.overdue
+			}
+			segments = append(segments, segment{" due " + dueString(due), dueStyle})
+		}
+		if item.Repeat != "" {
+			segments = append(segments, segment{" " + jot.RepeatString(item.Repeat), styles.date})
+		}
+		if len(item.Children) > 0 {
+			checked, total := item.Progress()
+			segments = append(segments, segment{fmt.Sprintf(" (%d/%d)", checked, total), bulletStyle})
+		}
+		segments = append(segments, segment{date, styles.date})
+		splitPrintSegments(prefix, bulletStyle, segments...)
+
+		displayItems(item.Children, list, path, indent, styles)
+	}
+}
+
+/* Splits text into segments in style, except for [[links]], which show the
+ * title of the note they lead to in the link style, or what was written in
+ * the broken link style if they lead nowhere. */
+func linkSegments(text string, style color.Style, styles itemStyles) []segment {
+	segments := []segment{}
+	for _, span := range jot.SplitLinks(text) {
+		switch {
+		case span.Link == nil:
+			segments = append(segments, segment{span.Text, style})
+		case span.Link.Err != nil:
+			segments = append(segments, segment{span.Text, styles.brokenLink})
+		default:
+			segments = append(segments, segment{"[[" + span.Link.Target.Title + "]]", styles.link})
+		}
+	}
+	return segments
+}
+
+/* Orders priorities from the highest to none. */
+func priorityRank(priority int) int {
+	if priority == 0 {
+		return jot.MaxPriority + 1
+	}
+	return priority
+}
+
 func displayNoteHeader(note jot.Note) {
 	// Load style settings
 	style := settings.GetStyle()
@@ -92,51 +196,155 @@ func displayNoteHeader(note jot.Note) {
 	titleStyle := color.New(color.FgColors[style.TitleColor], color.BgColors[style.TitleBackground])
 	dateStyle := color.New(color.FgColors[style.DateColor], color.BgColors[style.DateBackground])
 	idStyle := color.New(color.FgColors[style.IdColor], color.BgColors[style.IdBackground])
+	idPrefixStyle := color.New(color.FgColors[style.IdColor], color.BgColors[style.IdBackground], color.OpBold, color.OpUnderscore)
+	tagStyle := color.New(color.FgColors[style.TagColor], color.BgColors[style.TagBackground])
 
 	// Header
 	fmt.Println()
-	time := time.Unix(int64(note.Time), 0).Format("Jan 2 3:04 2006")
-	titleStyle.Printf(note.Title)
-	fmt.Println()
-	fmt.Print("Taken: ")
-	dateStyle.Printf("%v", time)
+	titleStyle.Print(note.Title)
+	printPinned(note)
 	fmt.Println()
+	printDates(note, dateStyle)
 	fmt.Print("ID: ")
-	idStyle.Printf(note.Id)
+	printId(note.Id, idPrefixStyle, idStyle)
+	printNotebook(note)
+	printTags(note, tagStyle)
+	if note.Deleted != 0 {
+		fmt.Println()
+		fmt.Print("Deleted: ")
+		dateStyle.Print(time.Unix(note.Deleted, 0).Format("Jan 2 3:04 2006"))
+	}
 
 	fmt.Println()
 }
 
-func DisplayNoteById(id string) {
-	note, found := jot.GetNoteById(id)
-	if found {
-		displayNote(note)
-	} else {
-		fmt.Println("No note found.")
+/* Formats a due date shortly, with the year only if it is not this year. */
+func dueString(due time.Time) string {
+	if due.Year() != time.Now().Year() {
+		return due.Format("Mon Jan 2 2006")
 	}
+	return due.Format("Mon Jan 2")
 }
 
-func DisplayNoteHeaderById(id string) {
-	note, found := jot.GetNoteById(id)
-	if found {
-		displayNoteHeader(note)
-	} else {
-		fmt.Println("No note found.")
+/* Returns whether a due date is before today. */
+func isOverdue(due time.Time) bool {
+	now := time.Now()
+	return due.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()))
+}
+
+/* Prints when note was created, if it has changed since modified, and if it
+ * is archived archived. */
+func printDates(note jot.Note, dateStyle color.Style) {
+	fmt.Print("Created: ")
+	dateStyle.Print(time.Unix(note.Created, 0).Format("Jan 2 3:04 2006"))
+	fmt.Println()
+	if note.Modified > note.Created {
+		fmt.Print("Modified: ")
+		dateStyle.Print(time.Unix(note.Modified, 0).Format("Jan 2 3:04 2006"))
+		fmt.Println()
+	}
+	if note.Archived != 0 {
+		fmt.Print("Archived: ")
+		dateStyle.Print(time.Unix(note.Archived, 0).Format("Jan 2 3:04 2006"))
+		fmt.Println()
+	}
+}
+
+/* Marks a pinned note after its title. */
+func printPinned(note jot.Note) {
+	if note.Pinned {
+		fmt.Print(" (pinned)")
 	}
 }
 
-func DisplayNoteByTitle(title string) {
-	id, found := jot.GetIdFromTitle(title)
-	if found {
-		DisplayNoteById(id)
+/* Prints the notebook of note, if it is in one, on a line of its own. */
+func printNotebook(note jot.Note) {
+	if note.Notebook == "" {
+		return
+	}
+	fmt.Println()
+	fmt.Print("Notebook: " + note.Notebook)
+}
+
+/* Prints the tags of note, if it has any, on a line of their own. */
+func printTags(note jot.Note, tagStyle color.Style) {
+	if len(note.Tags) == 0 {
+		return
 	}
+	fmt.Println()
+	fmt.Print("Tags: ")
+	tagStyle.Print("#" + strings.Join(note.Tags, " #"))
 }
 
-func DisplayNoteHeaderByTitle(title string) {
-	id, found := jot.GetIdFromTitle(title)
-	if found {
-		DisplayNoteHeaderById(id)
+/* Describes an item timestamp shortly, e.g. " (done Oct 3)", or returns ""
+ * if it is unknown. */
+func itemDate(what string, at int64) string {
+	if at == 0 {
+		return ""
+	}
+	t := time.Unix(at, 0)
+	format := "Jan 2"
+	if t.Year() != time.Now().Year() {
+		format = "Jan 2 2006"
 	}
+	return " (" + what + " " + t.Format(format) + ")"
+}
+
+/* Lengths of the shortest unique id prefixes, loaded on first use. */
+var shortIdLengths map[string]int
+
+/* Prints id with the shortest prefix that can be typed instead of it in
+ * prefixStyle and the rest in idStyle. */
+func printId(id string, prefixStyle, idStyle color.Style) {
+	short := shortId(id)
+	prefixStyle.Print(short)
+	idStyle.Print(id[len(short):])
+}
+
+/* Returns the shortest prefix of id that can be typed instead of it. */
+func shortId(id string) string {
+	if shortIdLengths == nil {
+		shortIdLengths, _ = jot.ShortIdLengths()
+	}
+	length, ok := shortIdLengths[id]
+	if !ok {
+		return id
+	}
+	return id[:length]
+}
+
+func DisplayNoteById(id string) error {
+	note, err := jot.GetNoteById(id)
+	if err != nil {
+		return err
+	}
+	displayNote(note)
+	return nil
+}
+
+func DisplayNoteHeaderById(id string) error {
+	note, err := jot.GetNoteById(id)
+	if err != nil {
+		return err
+	}
+	displayNoteHeader(note)
+	return nil
+}
+
+func DisplayNoteByTitle(title string) error {
+	id, err := jot.GetIdFromTitle(title)
+	if err != nil {
+		return err
+	}
+	return DisplayNoteById(id)
+}
+
+func DisplayNoteHeaderByTitle(title string) error {
+	id, err := jot.GetIdFromTitle(title)
+	if err != nil {
+		return err
+	}
+	return DisplayNoteHeaderById(id)
 }
 
 /* Displays the given notes to std out. */
@@ -153,62 +361,414 @@ func displayNotesHeaders(notes jot.Notes) {
 	}
 }
 
-/* Displays the stored notes to std out. */
-func DisplayAllNotes() {
-	displayNotes(jot.GetNotes())
+/* Displays the stored notes that pass filter to std out, ordered as by
+ * jot.SortNotes. */
+func DisplayAllNotes(sortBy string, filter jot.Filter) error {
+	notes, err := jot.GetNotes()
+	if err != nil {
+		return err
+	}
+	notes = jot.FilterNotes(notes, filter)
+	if err := jot.SortNotes(notes.Notes, sortBy); err != nil {
+		return err
+	}
+	displayNotes(notes)
+	return nil
+}
+
+/* Displays the headers of the stored notes that pass filter to std out,
+ * ordered as by jot.SortNotes. */
+func DisplayAllNoteHeaders(sortBy string, filter jot.Filter) error {
+	notes, err := jot.GetNotes()
+	if err != nil {
+		return err
+	}
+	notes = jot.FilterNotes(notes, filter)
+	if err := jot.SortNotes(notes.Notes, sortBy); err != nil {
+		return err
+	}
+	displayNotesHeaders(notes)
+	return nil
 }
 
-/* Displays the headers of the stored notes to std out. */
-func DisplayAllNoteHeaders() {
-	displayNotesHeaders(jot.GetNotes())
+/* Displays the headers of the notes in the trash to std out. */
+func DisplayTrash() error {
+	trash, err := jot.GetTrash()
+	if err != nil {
+		return err
+	}
+	if len(trash.Notes) == 0 {
+		fmt.Println("The trash is empty.")
+		return nil
+	}
+	displayNotesHeaders(trash)
+	return nil
 }
 
 /* Displays the last note taken to std out. */
-func DisplayLastNote() {
-	notes := jot.GetNotes()
+func DisplayLastNote() error {
+	notes, err := jot.GetNotes()
+	if err != nil {
+		return err
+	}
+	if len(notes.Notes) == 0 {
+		displayNoNotes()
+		return nil
+	}
 	displayNote(notes.Notes[len(notes.Notes)-1])
+	return nil
 }
 
 /* Displays the last note taken to std out. */
-func DisplayLastNoteHeader() {
-	notes := jot.GetNotes()
+func DisplayLastNoteHeader() error {
+	notes, err := jot.GetNotes()
+	if err != nil {
+		return err
+	}
+	if len(notes.Notes) == 0 {
+		displayNoNotes()
+		return nil
+	}
 	displayNoteHeader(notes.Notes[len(notes.Notes)-1])
+	return nil
 }
 
-/* Displays notes with any of the keywords in the title to std out. */
-func DisplayNotesBySearch(search string) {
-	notes := jot.GetNotes()
-	var filtered jot.Notes
-	keywords := strings.Split(search, " ")
+/* Displays the pinned notes, followed by the headers of the most recently
+ * modified other notes, as many as the listing settings say. If headers is
+ * set pinned notes are shown as headers too. */
+func DisplayPinnedAndRecent(headers bool) error {
+	notes, err := jot.GetNotes()
+	if err != nil {
+		return err
+	}
+	if len(notes.Notes) == 0 {
+		displayNoNotes()
+		return nil
+	}
+	jot.SortNotes(notes.Notes, jot.SortModified)
+
+	recent := jot.Notes{}
+	for _, note := range notes.Notes {
+		switch {
+		case note.Pinned && headers:
+			displayNoteHeader(note)
+		case note.Pinned:
+			displayNote(note)
+		case len(recent.Notes) < settings.GetListing().RecentNotes:
+			recent.Notes = append(recent.Notes, note)
+		}
+	}
+	displayNotesHeaders(recent)
+	return nil
+}
 
-	// First find notes with the keywords in the title
-	for i := 0; i < len(notes.Notes); i++ {
-		for j, found := 0, false; j < len(keywords) && !found; j++ {
-			if strings.Contains(strings.ToLower(notes.Notes[i].Title), strings.ToLower(keywords[j])) {
-				filtered.Notes = append(filtered.Notes, notes.Notes[i])
-				found = true
+func displayNoNotes() {
+	fmt.Println("There are no notes yet, create one with 'jot new [title]'.")
+}
+
+/* Displays notes with any of the keywords in the title that pass filter to
+ * std out. */
+func DisplayNotesBySearch(search string, filter jot.Filter) error {
+	notes, err := jot.SearchNotes(search)
+	if err != nil {
+		return err
+	}
+	displayNotes(jot.FilterNotes(notes, filter))
+	return nil
+}
+
+/* Displays the headers of notes with any of the keywords in the title that
+ * pass filter to std out. */
+func DisplayNotesHeadersBySearch(search string, filter jot.Filter) error {
+	notes, err := jot.SearchNotes(search)
+	if err != nil {
+		return err
+	}
+	displayNotesHeaders(jot.FilterNotes(notes, filter))
+	return nil
+}
+
+/* Displays tags and how many notes have them. */
+func DisplayTags(tags []jot.TagCount) {
+	style := settings.GetStyle()
+	tagStyle := color.New(color.FgColors[style.TagColor], color.BgColors[style.TagBackground])
+
+	if len(tags) == 0 {
+		fmt.Println("No notes are tagged.")
+		return
+	}
+	width := 0
+	for _, tag := range tags {
+		if len(tag.Tag) > width {
+			width = len(tag.Tag)
+		}
+	}
+	for _, tag := range tags {
+		tagStyle.Printf("#%-*s", width, tag.Tag)
+		fmt.Printf("  %d", tag.Notes)
+		fmt.Println()
+	}
+}
+
+/* Displays the agenda, overdue items in the overdue style. Each item is
+ * followed by the title and short id of the note it is on and its handle. */
+func DisplayAgenda(agenda jot.Agenda) {
+	style := settings.GetStyle()
+	headStyle := color.New(color.FgColors[style.TodoHeadColor], color.BgColors[style.TodoHeadBackground])
+	dateStyle := color.New(color.FgColors[style.DateColor], color.BgColors[style.DateBackground])
+	overdueStyle := color.New(color.FgColors[style.OverdueColor], color.BgColors[style.OverdueBackground])
+	itemStyle := color.New(color.FgColors[style.TodoItemColor], color.BgColors[style.TodoItemBackground])
+	idStyle := color.New(color.FgColors[style.IdColor], color.BgColors[style.IdBackground])
+	priorities := priorityStyles(style)
+
+	indent := strings.Repeat(" ", style.IndentWidth)
+	groups := []struct {
+		name    string
+		entries []jot.AgendaEntry
+	}{
+		{"Overdue", agenda.Overdue},
+		{"Today", agenda.Today},
+		{"This week", agenda.ThisWeek},
+		{"Later", agenda.Later},
+	}
+
+	empty := true
+	for _, group := range groups {
+		if len(group.entries) == 0 {
+			continue
+		}
+		empty = false
+		fmt.Println()
+		headStyle.Print(group.name + ":")
+		fmt.Println()
+		for _, entry := range group.entries {
+			due, _ := entry.Item.DueDate(time.Local)
+			entryDateStyle, entryItemStyle := dateStyle, itemStyle
+			if group.name == "Overdue" {
+				entryDateStyle, entryItemStyle = overdueStyle, overdueStyle
+			}
+			prefix := fmt.Sprintf("%s%-10s ", indent, dueString(due))
+			segments := []segment{}
+			if entry.Item.Priority != 0 {
+				segments = append(segments, segment{jot.PriorityString(entry.Item.Priority) + " ", priorities[entry.Item.Priority]})
 			}
+			segments = append(segments,
+				segment{entry.Item.Text, entryItemStyle},
+				segment{"  " + entry.NoteTitle + " (" + shortId(entry.NoteId) + " " + entry.Item.Handle + ")", idStyle})
+			splitPrintSegments(prefix, entryDateStyle, segments...)
 		}
 	}
-	displayNotes(filtered)
+	if empty {
+		fmt.Println("Nothing is due.")
+	}
 }
 
-/* Displays notes with any of the keywords in the title to std out. */
-func DisplayNotesHeadersBySearch(search string) {
-	notes := jot.GetNotes()
-	var filtered jot.Notes
-	keywords := strings.Split(search, " ")
+/* Displays the notebooks under root as a tree, each with how many notes and
+ * open items it holds, sub-notebooks included. */
+func DisplayNotebooks(root jot.Notebook) {
+	style := settings.GetStyle()
+	nameStyle := color.New(color.FgColors[style.TitleColor], color.BgColors[style.TitleBackground])
 
-	// First find notes with the keywords in the title
-	for i := 0; i < len(notes.Notes); i++ {
-		for j, found := 0, false; j < len(keywords) && !found; j++ {
-			if strings.Contains(strings.ToLower(notes.Notes[i].Title), strings.ToLower(keywords[j])) {
-				filtered.Notes = append(filtered.Notes, notes.Notes[i])
-				found = true
+	if len(root.Children) == 0 {
+		fmt.Println("No notes are in a notebook, use 'jot mv' or 'jot new --in' to file them.")
+		return
+	}
+
+	// own counts the notes of root that are in no notebook
+	own := jot.Notebook{Name: "(no notebook)", Notes: root.Notes, OpenItems: root.OpenItems}
+	for _, child := range root.Children {
+		own.Notes -= child.Notes
+		own.OpenItems -= child.OpenItems
+	}
+
+	width := len(own.Name)
+	var measure func(notebook jot.Notebook, depth int)
+	measure = func(notebook jot.Notebook, depth int) {
+		for _, child := range notebook.Children {
+			if w := 2*depth + len(child.Name); w > width {
+				width = w
 			}
+			measure(child, depth+1)
 		}
 	}
-	displayNotesHeaders(filtered)
+	measure(root, 0)
+
+	row := func(notebook jot.Notebook, depth int) {
+		name := strings.Repeat("  ", depth) + notebook.Name
+		nameStyle.Print(name)
+		fmt.Printf("%s  %s, %s", strings.Repeat(" ", width-len(name)), plural(notebook.Notes, "note"), plural(notebook.OpenItems, "open item"))
+		fmt.Println()
+	}
+	var tree func(notebook jot.Notebook, depth int)
+	tree = func(notebook jot.Notebook, depth int) {
+		for _, child := range notebook.Children {
+			row(child, depth)
+			tree(child, depth+1)
+		}
+	}
+	tree(root, 0)
+	if own.Notes > 0 {
+		row(own, 0)
+	}
+}
+
+/* Returns n and noun, in plural unless n is 1. */
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+/* Displays the headers of the notes linking to the note with id. */
+func DisplayBacklinks(id string) error {
+	linking, err := jot.GetBacklinks(id)
+	if err != nil {
+		return err
+	}
+	if len(linking.Notes) == 0 {
+		fmt.Println("No notes link to this note.")
+		return nil
+	}
+	displayNotesHeaders(linking)
+	return nil
+}
+
+/* Displays links, each with the title and short id of the note it leads to
+ * or why it is broken. */
+func DisplayLinks(links []jot.Link) {
+	if len(links) == 0 {
+		fmt.Println("This note has no links.")
+		return
+	}
+	for _, link := range links {
+		displayLink("", link)
+	}
+}
+
+/* Displays broken links along with the title and short id of the note they
+ * are written in. */
+func DisplayBrokenLinks(broken []jot.BrokenLink) {
+	style := settings.GetStyle()
+	titleStyle := color.New(color.FgColors[style.TitleColor], color.BgColors[style.TitleBackground])
+
+	if len(broken) == 0 {
+		fmt.Println("No links are broken.")
+		return
+	}
+	for i, b := range broken {
+		if i == 0 || b.Note.Id != broken[i-1].Note.Id {
+			titleStyle.Print(b.Note.Title)
+			fmt.Printf(" (%s)", shortId(b.Note.Id))
+			fmt.Println()
+		}
+		displayLink(strings.Repeat(" ", style.IndentWidth), b.Link)
+	}
+}
+
+func displayLink(indent string, link jot.Link) {
+	style := settings.GetStyle()
+	linkStyle := color.New(color.FgColors[style.LinkColor], color.BgColors[style.LinkBackground])
+	brokenStyle := color.New(color.FgColors[style.BrokenLinkColor], color.BgColors[style.BrokenLinkBackground])
+
+	fmt.Print(indent)
+	if link.Err != nil {
+		brokenStyle.Print("[[" + link.Ref + "]]")
+		fmt.Printf(" is broken: %v", link.Err)
+	} else {
+		linkStyle.Print("[[" + link.Ref + "]]")
+		fmt.Printf(" leads to: %s (%s)", link.Target.Title, shortId(link.Target.Id))
+	}
+	fmt.Println()
+}
+
+/* Displays the revisions of the note with id, oldest first. */
+func DisplayHistory(id string) error {
+	note, err := jot.GetNoteById(id)
+	if err != nil {
+		return err
+	}
+	style := settings.GetStyle()
+	titleStyle := color.New(color.FgColors[style.TitleColor], color.BgColors[style.TitleBackground])
+	dateStyle := color.New(color.FgColors[style.DateColor], color.BgColors[style.DateBackground])
+
+	titleStyle.Print(note.Title)
+	fmt.Println()
+	for i, revision := range note.Revisions {
+		lines := strings.Count(revision.Text, "\n")
+		fmt.Printf("%3d) replaced ", i)
+		dateStyle.Print(time.Unix(revision.Time, 0).Format("Jan 2 3:04 2006"))
+		fmt.Printf(", %d lines, titled: %s", lines, strings.SplitN(revision.Text, "\n", 2)[0])
+		fmt.Println()
+	}
+	fmt.Printf("%3d) current", len(note.Revisions))
+	fmt.Println()
+	return nil
+}
+
+/* Displays a diff, removed lines in the to-do heading style and added lines
+ * in the done heading style. */
+func DisplayDiff(diff []jot.DiffLine) {
+	style := settings.GetStyle()
+	contentStyle := color.New(color.FgColors[style.ContentColor], color.BgColors[style.ContentBackground])
+	removedStyle := color.New(color.FgColors[style.TodoHeadColor], color.BgColors[style.TodoHeadBackground])
+	addedStyle := color.New(color.FgColors[style.DoneHeadColor], color.BgColors[style.DoneHeadBackground])
+
+	for _, line := range diff {
+		switch line.Op {
+		case '-':
+			SplitPrintln("- ", line.Text, removedStyle, removedStyle)
+		case '+':
+			SplitPrintln("+ ", line.Text, addedStyle, addedStyle)
+		default:
+			SplitPrintln("  ", line.Text, contentStyle, contentStyle)
+		}
+	}
+}
+
+/* Displays the journal, most recent operation first. Undone operations that
+ * can still be redone are marked. */
+func DisplayJournal(journal jot.Journal) {
+	style := settings.GetStyle()
+	dateStyle := color.New(color.FgColors[style.DateColor], color.BgColors[style.DateBackground])
+
+	if len(journal.Operations) == 0 {
+		fmt.Println("No operations recorded.")
+		return
+	}
+	for i := len(journal.Operations) - 1; i >= 0; i-- {
+		op := journal.Operations[i]
+		state := "   "
+		if i >= journal.Position {
+			state = "(u)"
+		}
+		fmt.Printf("%s %3d) ", state, len(journal.Operations)-1-i)
+		dateStyle.Print(time.Unix(op.Time, 0).Format("Jan 2 3:04 2006"))
+		fmt.Print(" " + OperationString(op))
+		fmt.Println()
+	}
+	if journal.Position < len(journal.Operations) {
+		fmt.Println("(u) undone, can be redone")
+	}
+}
+
+/* Describes an operation in one line, e.g. "check 'item' on 'title'". */
+func OperationString(op jot.Operation) string {
+	note := op.After
+	if note == nil {
+		note = op.Before
+	}
+	s := op.Command
+	if op.Description != "" {
+		s += " " + op.Description
+	}
+	if note != nil {
+		if op.Description != "" {
+			s += " on"
+		}
+		s += " '" + note.Title + "'"
+	}
+	return s
 }
 
 // Helper functions
@@ -221,8 +781,8 @@ func SplitPrintln(prefix, str string, prefixStyle, strStyle color.Style) {
 
 	// no formating needed
 	if len(prefix)+len(str) <= width {
-		prefixStyle.Printf(prefix)
-		strStyle.Printf(str)
+		prefixStyle.Print(prefix)
+		strStyle.Print(str)
 		fmt.Println()
 		return
 	}
@@ -241,8 +801,8 @@ func SplitPrintln(prefix, str string, prefixStyle, strStyle color.Style) {
 
 	head := str[:breakIndex] // portion of str to print
 	str = str[breakIndex+1:]
-	prefixStyle.Printf(prefix)
-	strStyle.Printf(head)
+	prefixStyle.Print(prefix)
+	strStyle.Print(head)
 	fmt.Println()
 
 	for len(prefix)+len(str) > width {
@@ -252,16 +812,69 @@ func SplitPrintln(prefix, str string, prefixStyle, strStyle color.Style) {
 		}
 		head = str[:breakIndex]
 		str = str[breakIndex+1:]
-		fmt.Printf(tab)
-		strStyle.Printf(head)
+		fmt.Print(tab)
+		strStyle.Print(head)
 		fmt.Println()
 	}
 
 	if len(str) > 0 {
-		fmt.Printf(tab)
-		strStyle.Printf(str)
+		fmt.Print(tab)
+		strStyle.Print(str)
+		fmt.Println()
+	}
+}
+
+/* A piece of text printed in one style. */
+type segment struct {
+	text  string
+	style color.Style
+}
+
+/* Like SplitPrintln, for text made of segments in different styles. */
+func splitPrintSegments(prefix string, prefixStyle color.Style, segments ...segment) {
+	str := ""
+	owners := []int{} // the segment each byte of str belongs to
+	for i, seg := range segments {
+		str += seg.text
+		for range []byte(seg.text) {
+			owners = append(owners, i)
+		}
+	}
+
+	// prints str[from:to] a run of equally styled bytes at a time
+	printRange := func(from, to int) {
+		for from < to {
+			run := from
+			for run < to && owners[run] == owners[from] {
+				run++
+			}
+			segments[owners[from]].style.Print(str[from:run])
+			from = run
+		}
+	}
+
+	width := GetConsoleWidth() - len(prefix)
+	if width < 1 {
+		width = 1
+	}
+	tab := strings.Repeat(" ", len(prefix))
+
+	prefixStyle.Print(prefix)
+	start := 0
+	for len(str)-start > width {
+		breakIndex := findLastBreak(str[start:], width)
+		if breakIndex == -1 {
+			printRange(start, start+width)
+			start += width
+		} else {
+			printRange(start, start+breakIndex)
+			start += breakIndex + 1
+		}
 		fmt.Println()
+		fmt.Print(tab)
 	}
+	printRange(start, len(str))
+	fmt.Println()
 }
 
 /* find the last white space with respect to pos */
@@ -274,6 +887,11 @@ func findLastBreak(str string, pos int) int {
 	return -1
 }
 
+/* Width used when output does not go to a terminal. */
+const defaultConsoleWidth = 80
+
+/* Returns the width of the terminal, or defaultConsoleWidth if there is none
+ * to ask, e.g. when jot runs from cron or a pipe. */
 func GetConsoleWidth() int {
 	var fd int
 	if runtime.GOOS == "windows" {
@@ -283,8 +901,8 @@ func GetConsoleWidth() int {
 		fd = int(os.Stdin.Fd())
 	}
 	termWidth, _, err := terminal.GetSize(fd)
-	if err != nil {
-		panic(err.Error)
+	if err != nil || termWidth <= 0 {
+		return defaultConsoleWidth
 	}
 	return termWidth
 }
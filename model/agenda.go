@@ -0,0 +1,63 @@
+package jot
+
+import (
+	"sort"
+	"time"
+)
+
+/* An open item with a due date and the note it is on. */
+type AgendaEntry struct {
+	NoteId    string
+	NoteTitle string
+	Item      Item
+}
+
+/* Open items with a due date, grouped by when they are due. */
+type Agenda struct {
+	Overdue  []AgendaEntry
+	Today    []AgendaEntry
+	ThisWeek []AgendaEntry // the six days after today
+	Later    []AgendaEntry
+}
+
+/* Returns every open item with a due date, sub-items included, grouped
+ * relative to the day of now. Each group is ordered by due date. */
+func GetAgenda(now time.Time) (Agenda, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	week := today.AddDate(0, 0, 7)
+
+	notes, err := listNotes()
+	if err != nil {
+		return Agenda{}, err
+	}
+	entries := []AgendaEntry{}
+	for _, note := range live(notes) {
+		// open sub-items of done items count too
+		collect := func(item *Item) {
+			if _, ok := item.DueDate(now.Location()); ok && !item.Checked {
+				entries = append(entries, AgendaEntry{note.Id, note.Title, *item})
+			}
+		}
+		walkItems(note.Todo, collect)
+		walkItems(note.Done, collect)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Item.Due < entries[j].Item.Due
+	})
+
+	var agenda Agenda
+	for _, entry := range entries {
+		due, _ := entry.Item.DueDate(now.Location())
+		switch {
+		case due.Before(today):
+			agenda.Overdue = append(agenda.Overdue, entry)
+		case due.Equal(today):
+			agenda.Today = append(agenda.Today, entry)
+		case due.Before(week):
+			agenda.ThisWeek = append(agenda.ThisWeek, entry)
+		default:
+			agenda.Later = append(agenda.Later, entry)
+		}
+	}
+	return agenda, nil
+}
@@ -0,0 +1,102 @@
+package jot
+
+import (
+	"time"
+)
+
+/* Whether archived notes are listed, searched, matched by title and counted
+ * by the agenda, tags and notebooks. They are hidden by default. */
+var showArchived = false
+
+/* Makes archived notes show up alongside the others, or hides them again. */
+func ShowArchived(show bool) {
+	showArchived = show
+}
+
+/* Given an id, archive the note with this id, or take it out of the archive,
+ * and return its title. */
+func ArchiveNote(id string, archived bool) (title string, err error) {
+	end, err := begin()
+	if err != nil {
+		return "", err
+	}
+	defer end()
+
+	note, err := GetNoteById(id)
+	if err != nil {
+		return "", err
+	}
+	command := "archive"
+	note.Archived = time.Now().Unix()
+	if !archived {
+		command = "unarchive"
+		note.Archived = 0
+		note.Unarchived = time.Now().Unix()
+	}
+	return note.Title, writeNote(command, "", note)
+}
+
+/* How AutoArchive records archiving a note in the journal. */
+const autoArchiveCommand = "auto-archive"
+
+/* Archives every note that is not pinned, has checked items and no open ones,
+ * and got its last item checked, or was last taken out of the archive, longer
+ * than after ago. Return the titles of the archived notes. */
+func AutoArchive(after time.Duration) (titles []string, err error) {
+	end, err := begin()
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	notes, err := listNotes()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, note := range notes {
+		if note.Deleted != 0 || note.Archived != 0 || note.Pinned {
+			continue
+		}
+		if since, ok := todoEmptySince(note); !ok || now.Sub(since) < after {
+			continue
+		}
+		note.Archived = now.Unix()
+		err = writeNote(autoArchiveCommand, "", note)
+		if err != nil {
+			return titles, err
+		}
+		titles = append(titles, note.Title)
+	}
+	return titles, nil
+}
+
+/* Returns since when every item of note has been checked, the last time an
+ * item was checked, or when the note was last modified if that is unknown.
+ * Notes without items or with open items have no such time. */
+func todoEmptySince(note Note) (time.Time, bool) {
+	if len(note.Todo) != 0 || len(note.Done) == 0 {
+		return time.Time{}, false
+	}
+	open := false
+	last := int64(0)
+	walkItems(note.Done, func(item *Item) {
+		if !item.Checked {
+			open = true
+		}
+		if item.CompletedAt > last {
+			last = item.CompletedAt
+		}
+	})
+	if open {
+		return time.Time{}, false
+	}
+	if last == 0 {
+		last = note.Modified
+	}
+	// taking a note out of the archive starts over
+	if note.Unarchived > last {
+		last = note.Unarchived
+	}
+	return time.Unix(last, 0), true
+}
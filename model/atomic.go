@@ -0,0 +1,69 @@
+package jot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+/* Writes data to path without ever leaving a truncated file behind. The data
+ * goes to a temporary file in the same directory which is synced and then
+ * renamed over path. */
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	// clean up the temporary file unless it was renamed
+	defer os.Remove(tmpPath)
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+/* An advisory, exclusive lock held on a lock file. */
+type fileLock struct {
+	path string
+	file *os.File
+}
+
+/* Blocks until the lock at path is held. The lock file is created if needed. */
+func acquireLock(path string) (*fileLock, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err = lockFile(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &fileLock{path: path, file: file}, nil
+}
+
+/* Releases the lock. */
+func (l *fileLock) release() error {
+	err := unlockFile(l.file)
+	closeErr := l.file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
@@ -0,0 +1,103 @@
+package jot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors returned by the model, test for them with errors.Is
+var (
+	ErrNoteNotFound   = errors.New("no note found")
+	ErrItemOutOfRange = errors.New("out of range")
+	ErrItemNotFound   = errors.New("no item found")
+	ErrAmbiguousTitle = errors.New("more than one note matches the title")
+	ErrAmbiguousId    = errors.New("more than one note has an id starting with")
+	ErrStorage        = errors.New("cannot access notes")
+	ErrEditConflict   = errors.New("the note changed while it was being edited")
+)
+
+/* Wraps a failure of the store or journal. Matches ErrStorage. */
+type StorageError struct {
+	Err error
+}
+
+func (e *StorageError) Error() string {
+	return ErrStorage.Error() + ": " + e.Err.Error()
+}
+
+func (e *StorageError) Unwrap() error {
+	return e.Err
+}
+
+func (e *StorageError) Is(target error) bool {
+	return target == ErrStorage
+}
+
+/* Returned when a title matches more than one note. Matches ErrAmbiguousTitle. */
+type AmbiguousTitleError struct {
+	Title   string
+	Matches []Note
+}
+
+func (e *AmbiguousTitleError) Error() string {
+	ids := []string{}
+	for _, note := range e.Matches {
+		ids = append(ids, note.Id)
+	}
+	return fmt.Sprintf("%s '%s': %s", ErrAmbiguousTitle.Error(), e.Title, strings.Join(ids, ", "))
+}
+
+func (e *AmbiguousTitleError) Is(target error) bool {
+	return target == ErrAmbiguousTitle
+}
+
+/* Returned when an id prefix matches more than one note. Matches ErrAmbiguousId. */
+type AmbiguousIdError struct {
+	Prefix  string
+	Matches []Note
+}
+
+func (e *AmbiguousIdError) Error() string {
+	ids := []string{}
+	for _, note := range e.Matches {
+		ids = append(ids, note.Id)
+	}
+	return fmt.Sprintf("%s '%s': %s", ErrAmbiguousId.Error(), e.Prefix, strings.Join(ids, ", "))
+}
+
+func (e *AmbiguousIdError) Is(target error) bool {
+	return target == ErrAmbiguousId
+}
+
+/* Wraps err as a StorageError, nil stays nil. */
+func storageError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &StorageError{Err: err}
+}
+
+func noteNotFound(id string) error {
+	return fmt.Errorf("%w with id: %s", ErrNoteNotFound, id)
+}
+
+func titleNotFound(title string) error {
+	return fmt.Errorf("%w with title: %s", ErrNoteNotFound, title)
+}
+
+func editConflict(id string) error {
+	return fmt.Errorf("%w, note with id: %s", ErrEditConflict, id)
+}
+
+func itemNotFound(handle string) error {
+	return fmt.Errorf("%w with handle: %s", ErrItemNotFound, handle)
+}
+
+func itemOutOfRange(ref string, length int) error {
+	return fmt.Errorf("%w: item %s, the list has %d items", ErrItemOutOfRange, ref, length)
+}
+
+func subItemOutOfRange(ref, parent string, length int) error {
+	return fmt.Errorf("%w: item %s, item %s has %d sub-items", ErrItemOutOfRange, ref, parent, length)
+}
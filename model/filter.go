@@ -0,0 +1,25 @@
+package jot
+
+/* Which notes to list. The zero Filter matches every note. */
+type Filter struct {
+	Tag      string // only notes with this tag
+	Notebook string // only notes in this notebook or its sub-notebooks
+	Archived bool   // only archived notes
+}
+
+/* Returns whether note passes filter. */
+func (filter Filter) Match(note Note) bool {
+	return (filter.Tag == "" || note.HasTag(filter.Tag)) && note.InNotebook(filter.Notebook) &&
+		(!filter.Archived || note.Archived != 0)
+}
+
+/* Returns the notes that pass filter, in the same order. */
+func FilterNotes(notes Notes, filter Filter) Notes {
+	filtered := Notes{Version: notes.Version, Notes: []Note{}}
+	for _, note := range notes.Notes {
+		if filter.Match(note) {
+			filtered.Notes = append(filtered.Notes, note)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,99 @@
+package jot
+
+import (
+	"sort"
+	"strings"
+)
+
+/* The shortest prefix accepted in place of a full id. */
+const MinIdPrefix = 4
+
+/* Returns the full id of the note whose id is or starts with ref. Prefixes
+ * must be at least MinIdPrefix long. Notes in the trash count too, so a
+ * prefix keeps meaning the same note when it is deleted and restored.
+ * Returns an AmbiguousIdError listing the matches if there are several. */
+func ResolveId(ref string) (string, error) {
+	if _, found, err := store.Get(ref); err != nil {
+		return "", storageError(err)
+	} else if found {
+		return ref, nil
+	}
+	if len(ref) < MinIdPrefix {
+		return "", noteNotFound(ref)
+	}
+
+	ids, err := allIds()
+	if err != nil {
+		return "", err
+	}
+	matches := []Note{}
+	for _, id := range ids {
+		if strings.HasPrefix(id, ref) {
+			note, found, err := store.Get(id)
+			if err != nil {
+				return "", storageError(err)
+			}
+			if found {
+				matches = append(matches, note)
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", noteNotFound(ref)
+	case 1:
+		return matches[0].Id, nil
+	default:
+		return "", &AmbiguousIdError{Prefix: ref, Matches: matches}
+	}
+}
+
+/* Returns, for every note, the length of the shortest prefix of its id that
+ * ResolveId resolves to it. Never less than MinIdPrefix. */
+func ShortIdLengths() (map[string]int, error) {
+	ids, err := allIds()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+
+	lengths := map[string]int{}
+	for i, id := range ids {
+		length := MinIdPrefix
+		// in sorted order the longest shared prefix is with a neighbour
+		if i > 0 && commonPrefix(id, ids[i-1])+1 > length {
+			length = commonPrefix(id, ids[i-1]) + 1
+		}
+		if i < len(ids)-1 && commonPrefix(id, ids[i+1])+1 > length {
+			length = commonPrefix(id, ids[i+1]) + 1
+		}
+		if length > len(id) {
+			length = len(id)
+		}
+		lengths[id] = length
+	}
+	return lengths, nil
+}
+
+/* Returns the ids of every note, including those in the trash. */
+func allIds() ([]string, error) {
+	if index, ok := store.(IdIndex); ok {
+		ids, err := index.Ids()
+		return ids, storageError(err)
+	}
+	notes, err := listNotes()
+	ids := []string{}
+	for _, note := range notes {
+		ids = append(ids, note.Id)
+	}
+	return ids, err
+}
+
+func commonPrefix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
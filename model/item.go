@@ -0,0 +1,450 @@
+package jot
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* An item of a note's checklist. Handle identifies the item within its note
+ * and stays the same while other items come and go, unlike its index.
+ * CompletedAt is 0 while the item is to be done, and for items completed
+ * before jot kept track. Due is a date, YYYY-MM-DD, written @due(YYYY-MM-DD)
+ * in the item. Priority is 1 for the most important items to MaxPriority, or 0
+ * for none, written !1 or (A) in the item. Repeat is a recurrence rule,
+ * written @every(rule) in the item, see parseRepeat. Children are the item's
+ * sub-items, which can be checked on their own. */
+type Item struct {
+	Handle      string `json:"handle"`
+	Text        string `json:"text"`
+	Checked     bool   `json:"checked,omitempty"`
+	CreatedAt   int64  `json:"created-at"`
+	CompletedAt int64  `json:"completed-at,omitempty"`
+	Due         string `json:"due,omitempty"`
+	Priority    int    `json:"priority,omitempty"`
+	Repeat      string `json:"repeat,omitempty"`
+	Children    []Item `json:"children,omitempty"`
+}
+
+/* The layout of due dates. */
+const DateLayout = "2006-01-02"
+
+var dueAnnotation = regexp.MustCompile(`\s*@due\((\d{4}-\d{2}-\d{2})\)`)
+
+/* The lowest priority, priorities go from 1 to MaxPriority. */
+const MaxPriority = 3
+
+/* !1 to !3 anywhere as a word, or (A) to (C) at the start as in todo.txt. */
+var priorityAnnotation = regexp.MustCompile(`(^|\s)!([1-3])(\s|$)|^\(([A-C])\)(\s|$)`)
+
+/* Returns an item with text, see setItemText. */
+func newItem(text string) Item {
+	var item Item
+	setItemText(&item, text)
+	return item
+}
+
+/* Sets the text of item as written by the user, taking annotations such as
+ * @due(2026-10-20), !1 or @every(week) out of the text into their own fields. */
+func setItemText(item *Item, text string) {
+	item.Text, item.Due = takeDue(text)
+	item.Text, item.Repeat = takeRepeat(item.Text)
+	item.Text, item.Priority = takePriority(item.Text)
+}
+
+/* The inverse of setItemText, the text with its annotations. */
+func itemText(item Item) string {
+	text := item.Text
+	if item.Priority != 0 {
+		text += " " + PriorityString(item.Priority)
+	}
+	if item.Due != "" {
+		text += " @due(" + item.Due + ")"
+	}
+	if item.Repeat != "" {
+		text += " @every(" + item.Repeat + ")"
+	}
+	return text
+}
+
+/* Splits a valid @due annotation off text. */
+func takeDue(text string) (rest, due string) {
+	for _, match := range dueAnnotation.FindAllStringSubmatchIndex(text, -1) {
+		date := text[match[2]:match[3]]
+		if _, err := time.Parse(DateLayout, date); err == nil {
+			return strings.TrimSpace(text[:match[0]] + text[match[1]:]), date
+		}
+	}
+	return text, ""
+}
+
+/* Splits a priority annotation off text. */
+func takePriority(text string) (rest string, priority int) {
+	match := priorityAnnotation.FindStringSubmatch(text)
+	if match == nil {
+		return text, 0
+	}
+	if match[2] != "" {
+		priority = int(match[2][0] - '0')
+	} else {
+		priority = int(match[4][0]-'A') + 1
+	}
+	at := priorityAnnotation.FindStringIndex(text)
+	return strings.TrimSpace(text[:at[0]] + " " + text[at[1]:]), priority
+}
+
+/* Parses a priority given on its own, 1 to MaxPriority or A to C, optionally
+ * written as an annotation, e.g. !1 or (A). 0 or "none" is no priority. */
+func ParsePriority(given string) (int, error) {
+	s := strings.TrimSpace(given)
+	if s == "0" || strings.EqualFold(s, "none") {
+		return 0, nil
+	}
+	s = strings.TrimPrefix(s, "!")
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "("), ")")
+	if len(s) == 1 {
+		switch c := strings.ToUpper(s)[0]; {
+		case c >= '1' && c <= '0'+MaxPriority:
+			return int(c - '0'), nil
+		case c >= 'A' && c < 'A'+MaxPriority:
+			return int(c-'A') + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("'%s' is not a priority, use 1 (highest) to %d, A to %c, or none", given, MaxPriority, 'A'+MaxPriority-1)
+}
+
+/* Returns priority as written in items, e.g. !1, or "" for none. */
+func PriorityString(priority int) string {
+	if priority == 0 {
+		return ""
+	}
+	return "!" + strconv.Itoa(priority)
+}
+
+/* Returns the due date of item at midnight in loc, if it has one. */
+func (item Item) DueDate(loc *time.Location) (time.Time, bool) {
+	if item.Due == "" {
+		return time.Time{}, false
+	}
+	due, err := time.ParseInLocation(DateLayout, item.Due, loc)
+	return due, err == nil
+}
+
+/* Returns how many of the item's sub-items, at any depth, are checked and
+ * how many there are. */
+func (item Item) Progress() (checked, total int) {
+	walkItems(item.Children, func(child *Item) {
+		total++
+		if child.Checked {
+			checked++
+		}
+	})
+	return
+}
+
+/* Calls fn on every item in items and their sub-items, parents first. */
+func walkItems(items []Item, fn func(item *Item)) {
+	for i := range items {
+		fn(&items[i])
+		walkItems(items[i].Children, fn)
+	}
+}
+
+/* Letters handles are made of. No digits, so a handle never reads as an
+ * index, and no look-alikes of digits. */
+const handleAlphabet = "abcdefghjkmnpqrstuvwxyz"
+
+const handleLength = 3
+
+/* Gives every item of note without a handle one that no other item of the
+ * note has. Handles are derived from the note id and the item text, so
+ * notes read from files that predate handles get the same ones every time. */
+func assignHandles(note *Note) {
+	taken := map[string]bool{}
+	mark := func(item *Item) {
+		taken[item.Handle] = true
+	}
+	walkItems(note.Todo, mark)
+	walkItems(note.Done, mark)
+
+	assign := func(item *Item) {
+		if item.Handle != "" {
+			return
+		}
+		for attempt := 0; ; attempt++ {
+			handle := makeHandle(note.Id + "/" + item.Text + "/" + strconv.Itoa(attempt))
+			if !taken[handle] {
+				item.Handle = handle
+				taken[handle] = true
+				return
+			}
+		}
+	}
+	walkItems(note.Todo, assign)
+	walkItems(note.Done, assign)
+}
+
+/* Calls assignHandles on every note. */
+func assignAllHandles(notes []Note) {
+	for i := range notes {
+		assignHandles(&notes[i])
+	}
+}
+
+func makeHandle(seed string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(seed))
+	n := hash.Sum32()
+	handle := ""
+	for i := 0; i < handleLength; i++ {
+		handle += string(handleAlphabet[n%uint32(len(handleAlphabet))])
+		n /= uint32(len(handleAlphabet))
+	}
+	return handle
+}
+
+// Prefixes of addresses that name the list they are in
+const (
+	TodoPrefix = "t"
+	DonePrefix = "d"
+)
+
+/* Finds the item of note ref refers to: a handle anywhere in the note, or an
+ * index or address such as 2.1 (the first sub-item of item 2) in the to-do
+ * list, or the done list if done is set. An address starting with TodoPrefix
+ * or DonePrefix, e.g. d2.1, is in that list whatever done is. Returns the list
+ * the item is in and its path there, the index at each depth. */
+func findItem(note *Note, ref string, done bool) (list *[]Item, path []int, err error) {
+	for _, list := range []*[]Item{&note.Todo, &note.Done} {
+		if path := findHandle(*list, ref); path != nil {
+			return list, path, nil
+		}
+	}
+
+	address := ref
+	if strings.HasPrefix(ref, TodoPrefix) || strings.HasPrefix(ref, DonePrefix) {
+		address, done = ref[1:], strings.HasPrefix(ref, DonePrefix)
+	}
+	list, prefix := &note.Todo, ""
+	if done {
+		list, prefix = &note.Done, DonePrefix
+	}
+	level := *list
+	for _, part := range strings.Split(address, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, nil, itemNotFound(ref)
+		}
+		if (n < 0 || n >= len(level)) && path == nil {
+			return nil, nil, itemOutOfRange(ref, len(level))
+		}
+		if n < 0 || n >= len(level) {
+			return nil, nil, subItemOutOfRange(ref, prefix+AddressString(path), len(level))
+		}
+		path = append(path, n)
+		level = level[n].Children
+	}
+	return list, path, nil
+}
+
+/* Returns whether item or, if children is set, any of its sub-items is not
+ * in the checked state. */
+func canCheck(item Item, checked, children bool) bool {
+	if item.Checked != checked {
+		return true
+	}
+	if children {
+		for _, child := range item.Children {
+			if canCheck(child, checked, children) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/* Returns the path of the item with handle in items, or nil. */
+func findHandle(items []Item, handle string) []int {
+	for i, item := range items {
+		if item.Handle == handle {
+			return []int{i}
+		}
+		if path := findHandle(item.Children, handle); path != nil {
+			return append([]int{i}, path...)
+		}
+	}
+	return nil
+}
+
+/* Returns the item at path in items. */
+func itemAt(items []Item, path []int) *Item {
+	item := &items[path[0]]
+	for _, i := range path[1:] {
+		item = &item.Children[i]
+	}
+	return item
+}
+
+/* Removes the item at path from items and returns it. */
+func removeItemAt(items *[]Item, path []int) Item {
+	for _, i := range path[:len(path)-1] {
+		items = &(*items)[i].Children
+	}
+	i := path[len(path)-1]
+	item := (*items)[i]
+	*items = append((*items)[:i:i], (*items)[i+1:]...)
+	if len(*items) == 0 && len(path) > 1 {
+		*items = nil
+	}
+	return item
+}
+
+/* Parses an address such as 2 or 2.1 into a path. */
+func ParseAddress(address string) ([]int, error) {
+	path := []int{}
+	for _, part := range strings.Split(address, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("'%s' is not an item number or address such as 2.1", address)
+		}
+		path = append(path, n)
+	}
+	return path, nil
+}
+
+/* Returns the list an item at path in items would be in: items itself or
+ * the sub-items of the item at the parent path. The last index of path may
+ * be one past the end of that list. address is path as given, for errors. */
+func listAt(items *[]Item, path []int, address string) (*[]Item, error) {
+	for depth, i := range path[:len(path)-1] {
+		if i >= len(*items) && depth == 0 {
+			return nil, itemOutOfRange(address, len(*items))
+		}
+		if i >= len(*items) {
+			return nil, subItemOutOfRange(address, AddressString(path[:depth]), len(*items))
+		}
+		items = &(*items)[i].Children
+	}
+	if path[len(path)-1] > len(*items) {
+		return nil, fmt.Errorf("%w: cannot move to %s, the list has %d items", ErrItemOutOfRange, address, len(*items))
+	}
+	return items, nil
+}
+
+/* Inserts item into items at path, before the item that was there. */
+func insertItemAt(items *[]Item, path []int, item Item) {
+	for _, i := range path[:len(path)-1] {
+		items = &(*items)[i].Children
+	}
+	i := path[len(path)-1]
+	*items = append((*items)[:i], append([]Item{item}, (*items)[i:]...)...)
+}
+
+/* Returns path as an address, e.g. 2.1. */
+func AddressString(path []int) string {
+	parts := []string{}
+	for _, i := range path {
+		parts = append(parts, strconv.Itoa(i))
+	}
+	return strings.Join(parts, ".")
+}
+
+/* Checks or unchecks item, and if children is set its sub-items too. Items
+ * that already were in that state keep their completion time. */
+func setChecked(item *Item, checked, children bool, now int64) {
+	if item.Checked != checked {
+		item.Checked = checked
+		item.CompletedAt = 0
+		if checked {
+			item.CompletedAt = now
+		}
+	}
+	if children {
+		for i := range item.Children {
+			setChecked(&item.Children[i], checked, children, now)
+		}
+	}
+}
+
+/* Carries the handles and timestamps of the items of note over to the items
+ * of newNote with the same text, so editing a note does not make all of its
+ * items new. An item that is now done but was not gets completed now. */
+func keepItems(note Note, newNote *Note) {
+	previous := [][]Item{note.Todo, note.Done}
+	matchItems(previous, [][]Item{newNote.Todo, newNote.Done}, func(item *Item, previous Item) {
+		item.Handle = previous.Handle
+		item.CreatedAt = previous.CreatedAt
+		switch {
+		case !item.Checked:
+			item.CompletedAt = 0
+		case previous.Checked:
+			item.CompletedAt = previous.CompletedAt
+		default:
+			item.CompletedAt = time.Now().Unix()
+		}
+	})
+}
+
+/* Calls keep on every item of lists, sub-items included, that has the same
+ * text as an item of previous, along with that item. Each item of previous
+ * is used at most once, for repeated items in order. */
+func matchItems(previous, lists [][]Item, keep func(item *Item, previous Item)) {
+	old := map[string][]Item{}
+	for _, items := range previous {
+		walkItems(items, func(item *Item) {
+			old[item.Text] = append(old[item.Text], *item)
+		})
+	}
+	for _, items := range lists {
+		walkItems(items, func(item *Item) {
+			candidates := old[item.Text]
+			if len(candidates) == 0 {
+				return
+			}
+			old[item.Text] = candidates[1:]
+			keep(item, candidates[0])
+		})
+	}
+}
+
+/* Returns the indentation of an item line, e.g. 1 for " - item" and 3 for
+ * "   X sub-item", or -1 if line is not an item. */
+func itemIndent(line string) int {
+	rest := strings.TrimLeft(line, " ")
+	indent := len(line) - len(rest)
+	if indent == 0 || !(strings.HasPrefix(rest, "- ") || strings.HasPrefix(rest, "X ")) {
+		return -1
+	}
+	return indent
+}
+
+/* Parses the item on the first of lines along with the more indented items
+ * following it as its sub-items. Returns how many lines it used. */
+func parseItem(lines []string) (item Item, used int) {
+	indent := itemIndent(lines[0])
+	item = newItem(lines[0][indent+2:])
+	item.Checked = lines[0][indent] == 'X'
+	used = 1
+	for used < len(lines) && itemIndent(lines[used]) > indent {
+		child, n := parseItem(lines[used:])
+		item.Children = append(item.Children, child)
+		used += n
+	}
+	return
+}
+
+/* The inverse of parseItem, depth is how deep item is nested. */
+func itemToString(item Item, depth int) string {
+	mark := " - "
+	if item.Checked {
+		mark = " X "
+	}
+	s := strings.Repeat("  ", depth) + mark + itemText(item) + "\n"
+	for _, child := range item.Children {
+		s += itemToString(child, depth+1)
+	}
+	return s
+}
@@ -0,0 +1,161 @@
+package jot
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const nested = `groceries
+ - bread
+   - rye
+   X spelt
+ - milk
+ X eggs
+   - brown
+`
+
+func TestFindItem(t *testing.T) {
+	note := parseNote(nested)
+	assignHandles(&note)
+	spelt := note.Todo[0].Children[1].Handle
+
+	tests := []struct {
+		ref  string
+		done bool
+		list *[]Item
+		path []int
+		err  error
+	}{
+		{"1", false, &note.Todo, []int{1}, nil},
+		{"0.1", false, &note.Todo, []int{0, 1}, nil},
+		{"0", true, &note.Done, []int{0}, nil},
+		{"0.0", true, &note.Done, []int{0, 0}, nil},
+		{"d0.0", false, &note.Done, []int{0, 0}, nil},
+		{"t0.1", true, &note.Todo, []int{0, 1}, nil},
+		{spelt, true, &note.Todo, []int{0, 1}, nil},
+		{"2", false, nil, nil, ErrItemOutOfRange},
+		{"0.2", false, nil, nil, ErrItemOutOfRange},
+		{"0.1", true, nil, nil, ErrItemOutOfRange},
+		{"d", false, nil, nil, ErrItemNotFound},
+		{"zzz", false, nil, nil, ErrItemNotFound},
+	}
+	for _, test := range tests {
+		list, path, err := findItem(&note, test.ref, test.done)
+		if !errors.Is(err, test.err) || list != test.list || !reflect.DeepEqual(path, test.path) {
+			t.Errorf("findItem(%q, %v): got %v %v %v, want %v %v", test.ref, test.done, list == &note.Todo, path, err, test.path, test.err)
+		}
+	}
+}
+
+func TestCheckAndUncheckSubItems(t *testing.T) {
+	id := useNotes(t, nested)[0]
+
+	// spelt is checked and shown as 0.1 under to-do
+	if _, err := CheckItem(id, "0.1", false); err == nil || !strings.Contains(err.Error(), "already checked") {
+		t.Errorf("checking a checked item: got %v, want an error", err)
+	}
+	if _, err := UnCheckItem(id, "0.1"); !errors.Is(err, ErrItemOutOfRange) {
+		t.Errorf("unchecking 0.1 should look in the done list only: got %v", err)
+	}
+	item, err := UnCheckItem(id, "t0.1")
+	if err != nil || item != "spelt" {
+		t.Fatalf("unchecking t0.1: got %q, %v, want spelt", item, err)
+	}
+	if note := mustGetNote(t, id); note.Todo[0].Children[1].Checked {
+		t.Error("spelt is still checked")
+	}
+
+	// brown is open and shown as d0.0 under done
+	if _, err = UnCheckItem(id, "0.0"); err == nil || !strings.Contains(err.Error(), "not checked") {
+		t.Errorf("unchecking an open item: got %v, want an error", err)
+	}
+	if item, err = CheckItem(id, "0.0", false); err != nil || item != "rye" {
+		t.Errorf("checking 0.0: got %q, %v, want rye", item, err)
+	}
+	// running the same command again must not check another item
+	if item, err = CheckItem(id, "0.0", false); err == nil {
+		t.Errorf("checking 0.0 again checked %q", item)
+	}
+	if item, err = CheckItem(id, "d0.0", false); err != nil || item != "brown" {
+		t.Errorf("checking d0.0: got %q, %v, want brown", item, err)
+	}
+}
+
+func TestCheckMovesItemsBetweenLists(t *testing.T) {
+	id := useNotes(t, "groceries\n - bread\n - milk\n X eggs\n")[0]
+
+	if _, err := CheckItem(id, "1", false); err != nil {
+		t.Fatal(err)
+	}
+	note := mustGetNote(t, id)
+	if len(note.Todo) != 1 || note.Done[1].Text != "milk" || note.Done[1].CompletedAt == 0 {
+		t.Fatalf("milk should be done with a completion time: %+v", note.Done)
+	}
+	handle := note.Done[1].Handle
+
+	if _, err := UnCheckItem(id, handle); err != nil {
+		t.Fatal(err)
+	}
+	note = mustGetNote(t, id)
+	if last := note.Todo[len(note.Todo)-1]; last.Text != "milk" || last.Handle != handle || last.CompletedAt != 0 {
+		t.Errorf("milk should be back on the to-do list with its handle: %+v", last)
+	}
+}
+
+func TestKeepItemsByText(t *testing.T) {
+	note := parseNote("t\n - a\n - b\n - b\n")
+	assignHandles(&note)
+	edited := parseNote("t\n - new\n - b\n X a\n")
+	keepItems(note, &edited)
+
+	if edited.Todo[1].Handle != note.Todo[1].Handle {
+		t.Error("the first b should keep the handle of the first b")
+	}
+	if a := edited.Done[0]; a.Handle != note.Todo[0].Handle || a.CompletedAt == 0 {
+		t.Errorf("a should keep its handle and be completed now: %+v", a)
+	}
+	if edited.Todo[0].Handle != "" {
+		t.Error("a new item should not take over a handle")
+	}
+}
+
+func TestCheckRecursive(t *testing.T) {
+	id := useNotes(t, nested)[0]
+
+	if _, err := CheckItem(id, "0", true); err != nil {
+		t.Fatal(err)
+	}
+	note := mustGetNote(t, id)
+	bread := note.Done[len(note.Done)-1]
+	if checked, total := bread.Progress(); bread.Text != "bread" || checked != total {
+		t.Errorf("bread and all of its sub-items should be checked: %+v", bread)
+	}
+	// eggs is checked but brown is not, so -r still has something to do
+	eggs := note.Done[0].Handle
+	if _, err := CheckItem(id, eggs, true); err != nil {
+		t.Errorf("checking eggs with its sub-items: %v", err)
+	}
+	if _, err := CheckItem(id, eggs, true); err == nil {
+		t.Error("checking an item whose sub-items are all checked should fail")
+	}
+}
+
+func TestMoveItem(t *testing.T) {
+	id := useNotes(t, "t\n - a\n - b\n - c\n")[0]
+
+	if _, err := MoveItem(id, "2", "0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MoveItem(id, "2", "0.0"); err != nil {
+		t.Fatal(err)
+	}
+	note := mustGetNote(t, id)
+	if texts(note.Todo) != "c a" || texts(note.Todo[0].Children) != "b" {
+		t.Errorf("got %s with %s under it, want c a with b under c", texts(note.Todo), texts(note.Todo[0].Children))
+	}
+	if _, err := MoveItem(id, "0", "5"); !errors.Is(err, ErrItemOutOfRange) {
+		t.Errorf("moving past the end: got %v", err)
+	}
+}
@@ -1,10 +1,12 @@
 package jot
 
 import (
-	"encoding/json"
+	_ "embed"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -13,283 +15,691 @@ import (
 
 // Reading and writting
 
-/* An object representing a single note. */
+/* An object representing a single note. Modified is when its content last
+ * changed, moving it to or from the trash, the archive or another notebook or
+ * pinning it does not count. Tags are the #tags written in its title, lines
+ * and items, kept up to date as it changes. Notebook is the path of the
+ * notebook the note is in, e.g. work/clients/acme, or "" for none. Unarchived
+ * is when the note was last taken out of the archive. */
 type Note struct {
-	Id    string   `json:"id"`
-	Title string   `json:"title"`
-	Time  int64    `json:"time"`
-	Lines []string `json:"lines"`
-	Todo  []string `json:"to-do"`
-	Done  []string `json:"done"`
+	Id         string   `json:"id"`
+	Title      string   `json:"title"`
+	Created    int64    `json:"created"`
+	Modified   int64    `json:"modified"`
+	Lines      []string `json:"lines"`
+	Todo       []Item   `json:"to-do"`
+	Done       []Item   `json:"done"`
+	Tags       []string `json:"tags,omitempty"`
+	Notebook   string   `json:"notebook,omitempty"`
+	Pinned     bool     `json:"pinned,omitempty"`
+	Deleted    int64    `json:"deleted,omitempty"`
+	Archived   int64    `json:"archived,omitempty"`
+	Unarchived int64    `json:"unarchived,omitempty"`
+
+	Revisions []Revision `json:"revisions,omitempty"`
 }
 
 /* An object representing a collection of notes. */
 type Notes struct {
-	Notes []Note `json:"notes"`
+	Version int    `json:"version"`
+	Notes   []Note `json:"notes"`
 }
 
-var notes Notes
-var path string
+/* The notes written on first run. */
+//go:embed notes_default.json
+var defaultNotes []byte
 
-/* Load notes data and initialize path. */
-func init() {
-	// open and close settings
-	exePath, err := os.Executable()
+/* Creates a notes.json at path from the defaults if there is none yet,
+ * along with its directory. Returns whether the file was created. */
+func Bootstrap(path string) (created bool, err error) {
+	if _, err = os.Stat(path); err == nil || !os.IsNotExist(err) {
+		return false, err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return false, err
+	}
+	err = ioutil.WriteFile(path, defaultNotes, 0644)
+	return err == nil, err
+}
+
+/* Use a store of the named backend with its files in dataDir. */
+func Open(backend, dataDir string) error {
+	s, err := NewStore(backend, dataDir)
+	if err != nil {
+		return err
+	}
+	err = s.Load()
 	if err != nil {
-		panic(err.Error())
+		return storageError(err)
+	}
+	store = s
+	storePath = StoreLocation(backend, dataDir)
+	journalPath = filepath.Join(dataDir, "journal.json")
+	return storageError(loadJournal())
+}
+
+var lockDepth int
+
+/* Locks the store against other jot processes (if it supports locking) and
+ * reloads it, so the following load-modify-write can not lose another
+ * process' update. Calls may nest; the returned function releases the lock
+ * once the outermost call is done. */
+func begin() (end func(), err error) {
+	locker, ok := store.(Locker)
+	if !ok {
+		return func() {}, nil
+	}
+
+	if lockDepth == 0 {
+		err = locker.Lock()
+		if err != nil {
+			return nil, storageError(err)
+		}
+		err = store.Load()
+		if err == nil {
+			err = loadJournal()
+		}
+		if err != nil {
+			locker.Unlock()
+			return nil, storageError(err)
+		}
 	}
+	lockDepth++
 
-	path = filepath.Join(exePath, "../data/notes.json")
-	file, err := os.Open(path)
+	return func() {
+		lockDepth--
+		if lockDepth == 0 {
+			locker.Unlock()
+		}
+	}, nil
+}
+
+/* Copies every note from the current store into a new, empty store of the
+ * backend to, keeping its files in dataDir. Whatever is already where that
+ * store keeps its notes, e.g. left by an earlier migration, is moved aside
+ * first. Both stores are locked while copying. The current store stays in
+ * use. Returns the number of notes copied and where the old files were moved
+ * to, "" if there were none. */
+func MigrateStorage(to, dataDir string) (n int, backup string, err error) {
+	end, err := begin()
 	if err != nil {
-		panic(err.Error())
+		return 0, "", err
 	}
-	defer file.Close()
+	defer end()
 
-	bytes, _ := ioutil.ReadAll(file)
-	err = json.Unmarshal(bytes, &notes)
+	dst, err := NewStore(to, dataDir)
 	if err != nil {
-		panic(err.Error())
+		return 0, "", err
+	}
+	location := StoreLocation(to, dataDir)
+	if location == storePath {
+		return 0, "", fmt.Errorf("the notes are already stored in %s", location)
+	}
+	if _, err = os.Stat(location); err == nil {
+		backup = fmt.Sprintf("%s.%d.bak", location, time.Now().Unix())
+		err = os.Rename(location, backup)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return 0, "", storageError(err)
+	}
+
+	if locker, ok := dst.(Locker); ok {
+		err = locker.Lock()
+		if err != nil {
+			return 0, backup, storageError(err)
+		}
+		defer locker.Unlock()
+	}
+	// a missing notes.json simply means there is nothing there yet
+	if err = dst.Load(); err != nil && !os.IsNotExist(err) {
+		return 0, backup, storageError(err)
 	}
+	n, err = CopyNotes(dst, store)
+	return n, backup, err
 }
 
-/* Writes notes to path. */
-func writeNotes() {
-	bytes, err := json.MarshalIndent(notes, "", "    ")
+/* Writes note to the store and records the change in the journal as
+ * command, described by description. */
+func writeNote(command, description string, note Note) error {
+	var before *Note
+	old, found, err := store.Get(note.Id)
 	if err != nil {
-		panic(err.Error())
+		return storageError(err)
 	}
-	err = ioutil.WriteFile(filepath.Join(path), bytes, 0644)
+	if found {
+		before = &old
+	}
+	err = store.Put(note)
 	if err != nil {
-		panic(err.Error())
+		return storageError(err)
 	}
+	return record(command, description, before, &note)
+}
+
+/* Returns every note that is not in the trash or, unless they are shown,
+ * the archive. */
+func GetNotes() (Notes, error) {
+	notes, err := listNotes()
+	return Notes{Notes: live(notes)}, err
 }
 
-func GetNotes() Notes {
-	return notes
+/* Returns every note in the store, those in the trash included. */
+func listNotes() ([]Note, error) {
+	notes, err := store.List()
+	return notes, storageError(err)
+}
+
+/* Filters out notes in the trash and, unless they are shown, archived notes. */
+func live(notes []Note) []Note {
+	filtered := []Note{}
+	for _, note := range notes {
+		if visible(note) {
+			filtered = append(filtered, note)
+		}
+	}
+	return filtered
+}
+
+/* Returns whether note is neither in the trash nor, unless they are shown,
+ * archived. */
+func visible(note Note) bool {
+	return note.Deleted == 0 && (note.Archived == 0 || showArchived)
+}
+
+/* Returns the notes with any of the space separated keywords in the title. */
+func SearchNotes(search string) (Notes, error) {
+	keywords := strings.Split(search, " ")
+	if searcher, ok := store.(Searcher); ok {
+		found, err := searcher.Search(keywords)
+		return Notes{Notes: live(found)}, storageError(err)
+	}
+
+	notes, err := listNotes()
+	if err != nil {
+		return Notes{}, err
+	}
+	var filtered Notes
+	for _, note := range live(notes) {
+		for j, found := 0, false; j < len(keywords) && !found; j++ {
+			if strings.Contains(strings.ToLower(note.Title), strings.ToLower(keywords[j])) {
+				filtered.Notes = append(filtered.Notes, note)
+				found = true
+			}
+		}
+	}
+	return filtered, nil
+}
+
+// Orders accepted by SortNotes
+const (
+	SortCreated  = "created"
+	SortModified = "modified"
+)
+
+/* Sorts notes by creation, oldest first, or by modification, most recent
+ * first. */
+func SortNotes(notes []Note, by string) error {
+	switch by {
+	case SortCreated:
+		sort.SliceStable(notes, func(i, j int) bool {
+			return notes[i].Created < notes[j].Created
+		})
+	case SortModified:
+		sort.SliceStable(notes, func(i, j int) bool {
+			return notes[i].Modified > notes[j].Modified
+		})
+	default:
+		return fmt.Errorf("cannot sort notes by '%s', use %s or %s", by, SortCreated, SortModified)
+	}
+	return nil
 }
 
 // Management
 
-/* Given a string, make a new note and record it. Return the id of the new note */
-func NewNote(text string) string {
+/* Given a string, make a new note in the notebook path and record it. Return
+ * the id of the new note */
+func NewNote(text, path string) (string, error) {
+	end, err := begin()
+	if err != nil {
+		return "", err
+	}
+	defer end()
+
 	note := parseNote(text)
-	notes.Notes = append(notes.Notes, note)
-	writeNotes()
-	return note.Id
+	note.Notebook = CleanNotebook(path)
+	assignHandles(&note)
+	return note.Id, writeNote("new", "", note)
 }
 
-/* Given an id, delete the note with this id and return its title */
-func DeleteNote(id string) (deletedTitle string, found bool) {
-	found = false
-	deletedTitle = ""
-	for i := 0; i < len(notes.Notes); i++ {
-		if notes.Notes[i].Id == id {
-			found = true
-			deletedTitle = notes.Notes[i].Title
-			notes.Notes = append(notes.Notes[:i], notes.Notes[i+1:]...)
-			break
-		}
+/* Given an id, move the note with this id to the trash and return its title */
+func DeleteNote(id string) (deletedTitle string, err error) {
+	end, err := begin()
+	if err != nil {
+		return "", err
 	}
-	writeNotes()
-	return
+	defer end()
+
+	note, err := GetNoteById(id)
+	if err != nil {
+		return "", err
+	}
+	note.Deleted = time.Now().Unix()
+	return note.Title, writeNote("rm", "", note)
 }
 
-/* Given a title, delete the first note that has the same title.
+/* Given a title, delete the note that has this title.
  * Return the id of the deleted note */
-func DeleteNoteByTitle(title string) (deletedId string, found bool) {
-	deletedId, found = GetIdFromTitle(title)
-	if found {
-		DeleteNote(deletedId)
+func DeleteNoteByTitle(title string) (deletedId string, err error) {
+	end, err := begin()
+	if err != nil {
+		return "", err
 	}
+	defer end()
+
+	deletedId, err = GetIdFromTitle(title)
+	if err != nil {
+		return "", err
+	}
+	_, err = DeleteNote(deletedId)
 	return
 }
 
-/* Given the id of the note, check the nth item.
- * return the item and if the operation was successful. */
-func CheckItem(id string, n int) (item string, success bool) {
-	note, foundNote := GetNoteById(id)
-	foundItem := false
-	item = ""
-	if n < len(note.Todo) && n >= 0 && foundNote {
-		foundItem = true
-		item = note.Todo[n]
-		note.Todo = append(note.Todo[:n], note.Todo[n+1:]...)
-		note.Done = append(note.Done, item)
+/* Given an id, pin the note with this id, or unpin it, and return its title. */
+func PinNote(id string, pinned bool) (title string, err error) {
+	end, err := begin()
+	if err != nil {
+		return "", err
 	}
+	defer end()
 
-	success = false
-	if foundItem {
-		success = replaceNote(id, note)
-		if foundNote && foundItem && success {
-			writeNotes()
-		}
+	note, err := GetNoteById(id)
+	if err != nil {
+		return "", err
 	}
+	note.Pinned = pinned
+	command := "pin"
+	if !pinned {
+		command = "unpin"
+	}
+	return note.Title, writeNote(command, "", note)
+}
 
+/* Given the id of the note, check the to-do item ref, its handle, index or
+ * address. If children is set its sub-items are checked as well. A recurring
+ * item stays open, due next time, and a checked copy of it logs the
+ * completion. return the item. */
+func CheckItem(id, ref string, children bool) (item string, err error) {
+	err = updateNote("check", id, func(note *Note) (string, error) {
+		list, path, err := findItem(note, ref, false)
+		if err != nil {
+			return "", err
+		}
+		checked := itemAt(*list, path)
+		if !canCheck(*checked, true, children) {
+			return "", fmt.Errorf("%s is already checked", quote(checked.Text))
+		}
+		if checked.Repeat != "" && !checked.Checked {
+			item = checked.Text
+			recur(note, list, path, children, time.Now())
+			return quote(item), nil
+		}
+		setChecked(checked, true, children, time.Now().Unix())
+		item = checked.Text
+		// checked items move to the done list, sub-items stay with their parent
+		if list == &note.Todo && len(path) == 1 {
+			note.Done = append(note.Done, removeItemAt(list, path))
+		}
+		return quote(item), nil
+	})
 	return
 }
 
-func CheckItemByNoteTitle(title string, n int) (item string, success bool) {
-	id, found := GetIdFromTitle(title)
-	if found {
-		return CheckItem(id, n)
-	}
-	return "", found
+func CheckItemByNoteTitle(title, ref string, children bool) (item string, err error) {
+	err = byTitle(title, func(id string) error {
+		item, err = CheckItem(id, ref, children)
+		return err
+	})
+	return
 }
 
-/* Given the id of the note, uncheck the nth item.
- * return the item and if the operation was successful. */
-func UnCheckItem(id string, n int) (item string, success bool) {
-	note, foundNote := GetNoteById(id)
-	foundItem := false
-	item = ""
-	if n < len(note.Done) && n >= 0 && foundNote {
-		foundItem = true
-		item = note.Done[n]
-		note.Done = append(note.Done[:n], note.Done[n+1:]...)
-		note.Todo = append(note.Todo, item)
-	}
-
-	success = false
-	if foundItem {
-		success = replaceNote(id, note)
-		if foundNote && foundItem && success {
-			writeNotes()
+/* Given the id of the note, uncheck the done item ref, its handle, index or
+ * address. return the item. */
+func UnCheckItem(id, ref string) (item string, err error) {
+	err = updateNote("uncheck", id, func(note *Note) (string, error) {
+		list, path, err := findItem(note, ref, true)
+		if err != nil {
+			return "", err
 		}
-	}
+		unchecked := itemAt(*list, path)
+		if !unchecked.Checked {
+			return "", fmt.Errorf("%s is not checked", quote(unchecked.Text))
+		}
+		setChecked(unchecked, false, false, 0)
+		item = unchecked.Text
+		if list == &note.Done && len(path) == 1 {
+			note.Todo = append(note.Todo, removeItemAt(list, path))
+		}
+		return quote(item), nil
+	})
 	return
 }
 
-func UnCheckItemByNoteTitle(title string, n int) (item string, success bool) {
-	id, found := GetIdFromTitle(title)
-	if found {
-		return UnCheckItem(id, n)
-	}
-	return "", found
+func UnCheckItemByNoteTitle(title, ref string) (item string, err error) {
+	err = byTitle(title, func(id string) error {
+		item, err = UnCheckItem(id, ref)
+		return err
+	})
+	return
 }
 
-/* Given the id of the note, uncheck the nth item.
- * return the item and if the operation was successful. */
-func RemoveItem(id string, n int) (item string, success bool) {
-	note, foundNote := GetNoteById(id)
-	foundItem := false
-	item = ""
-	if n < len(note.Todo) && n >= 0 && foundNote {
-		foundItem = true
-		item = note.Todo[n]
-		note.Todo = append(note.Todo[:n], note.Todo[n+1:]...)
-	}
+/* Given the id of the note, remove the to-do item ref, its handle, index or
+ * address, along with its sub-items. return the item. */
+func RemoveItem(id, ref string) (item string, err error) {
+	err = updateNote("scratch", id, func(note *Note) (string, error) {
+		list, path, err := findItem(note, ref, false)
+		if err != nil {
+			return "", err
+		}
+		item = removeItemAt(list, path).Text
+		return quote(item), nil
+	})
+	return
+}
+
+func RemoveItemByNoteTitle(title, ref string) (item string, err error) {
+	err = byTitle(title, func(id string) error {
+		item, err = RemoveItem(id, ref)
+		return err
+	})
+	return
+}
 
-	success = false
-	if foundItem {
-		success = replaceNote(id, note)
-		if foundNote && foundItem && success {
-			writeNotes()
+/* Given the id of the note, add item to its to-do list. Annotations such as
+ * @due(2026-10-20) in item are parsed. A priority other than 0 overrides the
+ * one in item. return the handle of the new item. */
+func AddItem(id string, item string, priority int) (handle string, err error) {
+	err = updateNote("add", id, func(note *Note) (string, error) {
+		added := newItem(item)
+		added.CreatedAt = time.Now().Unix()
+		if priority != 0 {
+			added.Priority = priority
 		}
-	}
+		note.Todo = append(note.Todo, added)
+		assignHandles(note)
+		handle = note.Todo[len(note.Todo)-1].Handle
+		return quote(item), nil
+	})
 	return
 }
 
-func RemoveItemByNoteTitle(title string, n int) (item string, success bool) {
-	id, found := GetIdFromTitle(title)
-	if found {
-		return RemoveItem(id, n)
-	}
-	return "", found
+func AddItemByNoteTitle(title string, item string, priority int) (handle string, err error) {
+	err = byTitle(title, func(id string) error {
+		handle, err = AddItem(id, item, priority)
+		return err
+	})
+	return
 }
 
-/* Given the id of the note, uncheck the nth item.
- * return the item and if the operation was successful. */
-func AddItem(id string, item string) (success bool) {
-	note, foundNote := GetNoteById(id)
-	note.Todo = append(note.Todo, item)
+/* Given the id of the note, set the priority of the item ref, its handle,
+ * index or address in the to-do list. 0 clears it. return the item. */
+func SetItemPriority(id, ref string, priority int) (item string, err error) {
+	err = updateNote("priority", id, func(note *Note) (string, error) {
+		list, path, err := findItem(note, ref, false)
+		if err != nil {
+			return "", err
+		}
+		changed := itemAt(*list, path)
+		changed.Priority = priority
+		item = changed.Text
+		return quote(item) + " to " + priorityDescription(priority), nil
+	})
+	return
+}
 
-	success = false
-	if foundNote {
-		success = replaceNote(id, note)
-		if foundNote && success {
-			writeNotes()
+/* Given the id of the note, raise the priority of the item ref, its handle,
+ * index or address in the to-do list, by one. Items without a priority get
+ * the lowest, items with the highest keep it. return the item and its new
+ * priority. */
+func BumpItemPriority(id, ref string) (item string, priority int, err error) {
+	err = updateNote("bump", id, func(note *Note) (string, error) {
+		list, path, err := findItem(note, ref, false)
+		if err != nil {
+			return "", err
 		}
+		bumped := itemAt(*list, path)
+		switch {
+		case bumped.Priority == 0:
+			bumped.Priority = MaxPriority
+		case bumped.Priority > 1:
+			bumped.Priority--
+		}
+		item, priority = bumped.Text, bumped.Priority
+		return quote(item) + " to " + priorityDescription(priority), nil
+	})
+	return
+}
+
+func priorityDescription(priority int) string {
+	if priority == 0 {
+		return "no priority"
 	}
+	return PriorityString(priority)
+}
+
+/* Given the id of the note, move the item ref, its handle, index or address,
+ * within its list so that its address becomes to, e.g. 0 for the top of the
+ * list or 2.1 for the second sub-item of item 2, as numbered once the item is
+ * taken out of its place. Sub-items move along. return the item. */
+func MoveItem(id, ref, to string) (item string, err error) {
+	err = updateNote("move", id, func(note *Note) (string, error) {
+		list, path, err := findItem(note, ref, false)
+		if err != nil {
+			return "", err
+		}
+		target, err := ParseAddress(to)
+		if err != nil {
+			return "", err
+		}
+		moved := removeItemAt(list, path)
+		item = moved.Text
+		if _, err = listAt(list, target, to); err != nil {
+			return "", err
+		}
+		if len(target) == 1 && moved.Checked && list == &note.Todo {
+			return "", fmt.Errorf("cannot move %s to the top of the to-do list, it is checked", quote(item))
+		}
+		if len(target) == 1 && !moved.Checked && list == &note.Done {
+			return "", fmt.Errorf("cannot move %s to the top of the done list, it is not checked", quote(item))
+		}
+		insertItemAt(list, target, moved)
+		return quote(item) + " to " + to, nil
+	})
 	return
 }
 
-func AddItemByNoteTitle(title string, item string) (success bool) {
-	id, found := GetIdFromTitle(title)
-	if found {
-		return AddItem(id, item)
+func MoveItemByNoteTitle(title, ref, to string) (item string, err error) {
+	err = byTitle(title, func(id string) error {
+		item, err = MoveItem(id, ref, to)
+		return err
+	})
+	return
+}
+
+/* Given the ids of two notes, move the to-do item ref, its handle, index or
+ * address, of the first note to the end of the second, keeping its state,
+ * dates and sub-items. Checked items go to the done list. The item keeps its
+ * handle unless the other note already uses it. return the item. */
+func TransferItem(srcId, ref, dstId string) (item string, err error) {
+	end, err := begin()
+	if err != nil {
+		return "", err
+	}
+	defer end()
+
+	if srcId == dstId {
+		return "", fmt.Errorf("cannot transfer an item to the note it is on, use move to reorder it")
+	}
+	src, err := GetNoteById(srcId)
+	if err != nil {
+		return "", err
+	}
+	dst, err := GetNoteById(dstId)
+	if err != nil {
+		return "", err
+	}
+	list, path, err := findItem(&src, ref, false)
+	if err != nil {
+		return "", err
+	}
+	moved := removeItemAt(list, path)
+	item = moved.Text
+
+	// handles only have to be unique within a note
+	taken := map[string]bool{}
+	walkItems(dst.Todo, func(item *Item) { taken[item.Handle] = true })
+	walkItems(dst.Done, func(item *Item) { taken[item.Handle] = true })
+	transferred := []Item{moved}
+	walkItems(transferred, func(item *Item) {
+		if taken[item.Handle] {
+			item.Handle = ""
+		}
+	})
+	if moved.Checked {
+		dst.Done = append(dst.Done, transferred[0])
+	} else {
+		dst.Todo = append(dst.Todo, transferred[0])
+	}
+	assignHandles(&dst)
+
+	now := time.Now().Unix()
+	for _, note := range []*Note{&src, &dst} {
+		note.Modified = now
+		note.Tags = findTags(*note)
+	}
+	err = writeNote("transfer", quote(item)+" to "+quote(dst.Title), src)
+	if err != nil {
+		return "", err
+	}
+	err = writeNote("transfer", quote(item)+" from "+quote(src.Title), dst)
+	if err != nil {
+		return "", err
 	}
-	return found
+	return item, linkLastOperation()
 }
 
 /* Return the string representation of a Note */
-func GetNoteString(id string) (noteString string, success bool) {
-	var note Note
-	note, success = GetNoteById(id)
-	if success {
-		noteString = noteToString(note)
-	} else {
-		noteString = ""
+func GetNoteString(id string) (noteString string, err error) {
+	note, err := GetNoteById(id)
+	if err != nil {
+		return "", err
 	}
-	return
+	return noteToString(note), nil
 }
 
 /* Return the string representation of a Note with specified title */
-func GetNoteStringByTitle(title string) (noteString string, success bool) {
-	id, found := GetIdFromTitle(title)
-	if found {
-		return GetNoteString(id)
+func GetNoteStringByTitle(title string) (noteString string, err error) {
+	id, err := GetIdFromTitle(title)
+	if err != nil {
+		return "", err
 	}
-	return "", found
+	return GetNoteString(id)
 }
 
-/* Given an id and a string representation of a note, overwrite the note with id with the newNoteString */
-func EditNote(id, newNoteString string) bool {
-	// Create edited version of note
-	newNote := parseNote(newNoteString)
-	oldNote, found := GetNoteById(id)
-	if found {
-		newNote.Id = oldNote.Id
-		newNote.Time = oldNote.Time
+/* Given an id and a string representation of a note, overwrite the note with id with the newNoteString.
+ * oldNoteString is the note as it was when editing started, if the note no
+ * longer reads like that someone else changed it meanwhile and nothing is
+ * overwritten. The previous version is kept as a revision. */
+func EditNote(id, oldNoteString, newNoteString string) error {
+	return editNote("edit", "", id, oldNoteString, newNoteString)
+}
 
-		// write it
-		success := replaceNote(id, newNote)
-		writeNotes()
-		return success
-	} else {
-		return found
+/* Overwrite the note with id with newNoteString, recording it as command,
+ * unless it changed since it read oldNoteString. Nothing is written if
+ * newNoteString reads the same as the note. */
+func editNote(command, description, id, oldNoteString, newNoteString string) error {
+	end, err := begin()
+	if err != nil {
+		return err
 	}
+	defer end()
 
+	note, err := GetNoteById(id)
+	if err != nil {
+		return err
+	}
+	if noteToString(note) != oldNoteString {
+		return editConflict(id)
+	}
+	if noteToString(parseNote(newNoteString)) == oldNoteString {
+		return nil
+	}
+	return updateNote(command, id, func(note *Note) (string, error) {
+		// Create edited version of note
+		newNote := parseNote(newNoteString)
+		newNote.Id = note.Id
+		newNote.Created = note.Created
+		newNote.Notebook = note.Notebook
+		newNote.Pinned = note.Pinned
+		newNote.Archived, newNote.Unarchived = note.Archived, note.Unarchived
+		keepItems(*note, &newNote)
+		assignHandles(&newNote)
+		keepRevision(*note, &newNote)
+		*note = newNote
+		return description, nil
+	})
 }
 
-/* Given a path to the folder containing notes.json, an id to a note,
-and a item number to change, replace that list item with newItem. Return if
-the operation was succesful or not. */
-func EditListItem(id string, listItem int, newItem string) bool {
-	// find note
-	var noteToEdit Note
-	for _, note := range notes.Notes {
-		if note.Id == id {
-			noteToEdit = note
-			break
-		}
-	}
+/*
+	Given an id to a note, and the handle, index or address of a to-do item,
 
-	// replace list item
-	success := false
-	if listItem < len(noteToEdit.Todo) {
-		noteToEdit.Todo[listItem] = newItem
-		success = true
-		writeNotes()
-	}
-	return success
+replace the text of that list item with newItem.
+*/
+func EditListItem(id, ref string, newItem string) error {
+	return updateNote("amend", id, func(note *Note) (string, error) {
+		list, path, err := findItem(note, ref, false)
+		if err != nil {
+			return "", err
+		}
+		amended := itemAt(*list, path)
+		oldItem := itemText(*amended)
+		setItemText(amended, newItem)
+		return quote(oldItem) + " -> " + quote(newItem), nil
+	})
 }
 
 // Helper
 /* Parses a string into a note, assuming the first line is a title and lines
  * that begin with " - " are checklist items. */
 func parseNote(text string) Note {
+	lines := splitLines(text)
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+
+	var note Note
+	note.Id = xid.New().String()
+	note.Title = lines[0]
+	lines = lines[1:] // pop title
+	note.Created = time.Now().Unix()
+	note.Modified = note.Created
+	parseBody(&note, lines)
+	stamp := func(item *Item) {
+		item.CreatedAt = note.Created
+		if item.Checked {
+			item.CompletedAt = note.Created
+		}
+	}
+	walkItems(note.Todo, stamp)
+	walkItems(note.Done, stamp)
+	note.Tags = findTags(note)
+	// handles are assigned once the note has its final id
+	return note
+}
 
+/* Splits text into lines, dropping carriage returns and the empty string
+ * left after a final newline. */
+func splitLines(text string) []string {
 	lines := strings.Split(text, "\n")
 	for i := 0; i < len(lines); i++ {
 		lines[i] = strings.Trim(lines[i], "\r")
@@ -298,78 +708,177 @@ func parseNote(text string) Note {
 	if len(lines) > 0 && lines[len(lines)-1] == "" {
 		lines = lines[:len(lines)-1]
 	}
+	return lines
+}
 
-	var note Note
-	note.Id = xid.New().String()
-	note.Title = lines[0]
-	lines = lines[1:] // pop title
-	note.Time = time.Now().Unix()
+/* Fills the lines and checklists of note from the lines following its title.
+ * Items indented under an item are its sub-items. */
+func parseBody(note *Note, lines []string) {
 	note.Lines = []string{}
-	note.Todo = []string{}
-	note.Done = []string{}
-	for _, line := range lines {
-		if strings.HasPrefix(line, " - ") {
-			note.Todo = append(note.Todo, line[3:])
-		} else if strings.HasPrefix(line, " X ") {
-			note.Done = append(note.Done, line[3:])
+	note.Todo = []Item{}
+	note.Done = []Item{}
+	for i := 0; i < len(lines); i++ {
+		if itemIndent(lines[i]) != 1 {
+			note.Lines = append(note.Lines, lines[i])
+			continue
+		}
+		item, used := parseItem(lines[i:])
+		if item.Checked {
+			note.Done = append(note.Done, item)
 		} else {
-			note.Lines = append(note.Lines, line)
+			note.Todo = append(note.Todo, item)
 		}
+		i += used - 1
 	}
-	return note
 }
 
 func noteToString(note Note) string {
-	s := note.Title + "\n"
+	return note.Title + "\n" + bodyToString(note)
+}
+
+/* The inverse of parseBody. */
+func bodyToString(note Note) string {
+	s := ""
 	for _, line := range note.Lines {
 		s += line + "\n"
 	}
-	for _, line := range note.Todo {
-		s += " - " + line + "\n"
+	for _, item := range note.Todo {
+		s += itemToString(item, 0)
 	}
-	for _, line := range note.Done {
-		s += " X " + line + "\n"
+	for _, item := range note.Done {
+		s += itemToString(item, 0)
 	}
 	return s
 }
 
-func GetNoteById(id string) (note Note, found bool) {
-	found = false
-	for i := 0; i < len(notes.Notes); i++ {
-		if notes.Notes[i].Id == id {
-			found = true
-			note = notes.Notes[i]
-			break
-		}
+/* Returns the note with id, unless it is in the trash. */
+func GetNoteById(id string) (Note, error) {
+	note, found, err := store.Get(id)
+	if err != nil {
+		return Note{}, storageError(err)
 	}
-	return
+	if !found || note.Deleted != 0 {
+		return Note{}, noteNotFound(id)
+	}
+	return note, nil
 }
 
-func GetIdFromTitle(title string) (id string, found bool) {
-	found = false
-	id = ""
-	for i := 0; i < len(notes.Notes); i++ {
-		if notes.Notes[i].Title == title {
-			found = true
-			id = notes.Notes[i].Id
-		}
+/* Returns the id of the one note matching title, see FindNotesByTitle.
+ * Returns an AmbiguousTitleError listing the matches if there are several. */
+func GetIdFromTitle(title string) (string, error) {
+	matches, err := FindNotesByTitle(title)
+	if err != nil {
+		return "", err
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", titleNotFound(title)
+	case 1:
+		return matches[0].Id, nil
+	default:
+		return "", &AmbiguousTitleError{Title: title, Matches: matches}
 	}
-	return
 }
 
-/* Replace a note with id with the given note.
-This does not write the notes to file but simply mutates the global notes variable. */
-func replaceNote(id string, newNote Note) (success bool) {
-	success = false
+/* Returns every note matching title. Exact matches are preferred, then
+ * matches ignoring case, then titles starting with title ignoring case. Only
+ * the first of these to match anything is used, so "foo" finds the note
+ * titled "foo" even if there is also a "Foo" or a "foobar". */
+func FindNotesByTitle(title string) ([]Note, error) {
+	return findNotesByTitle(title, visible, true)
+}
 
-	for i := 0; i < len(notes.Notes); i++ {
-		// find note to replace
-		if notes.Notes[i].Id == id {
-			notes.Notes[i] = newNote
-			success = true
-			break
+/* Like FindNotesByTitle, among the notes for which keep is true. Titles
+ * starting with title only match if prefixes is set. */
+func findNotesByTitle(title string, keep func(note Note) bool, prefixes bool) ([]Note, error) {
+	if index, ok := store.(TitleIndex); ok {
+		ids, err := index.IdsByTitle(title)
+		if err != nil {
+			return nil, storageError(err)
+		}
+		matches := []Note{}
+		for _, id := range ids {
+			if note, err := GetNoteById(id); err == nil && keep(note) {
+				matches = append(matches, note)
+			}
+		}
+		if len(matches) > 0 {
+			return matches, nil
 		}
 	}
 
-	return
+	all, err := listNotes()
+	if err != nil {
+		return nil, err
+	}
+	notes := []Note{}
+	for _, note := range all {
+		if keep(note) {
+			notes = append(notes, note)
+		}
+	}
+	folded := strings.ToLower(title)
+	rules := []func(string) bool{
+		func(t string) bool { return t == title },
+		func(t string) bool { return strings.EqualFold(t, title) },
+	}
+	if prefixes {
+		rules = append(rules, func(t string) bool { return strings.HasPrefix(strings.ToLower(t), folded) })
+	}
+	for _, matches := range rules {
+		found := []Note{}
+		for _, note := range notes {
+			if matches(note.Title) {
+				found = append(found, note)
+			}
+		}
+		if len(found) > 0 {
+			return found, nil
+		}
+	}
+	return []Note{}, nil
+}
+
+/* Applies change to the note with id under lock and writes it back,
+ * recording it in the journal as command. change returns a description of
+ * what it did for the journal, e.g. the item it checked. */
+func updateNote(command, id string, change func(note *Note) (string, error)) error {
+	end, err := begin()
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	note, err := GetNoteById(id)
+	if err != nil {
+		return err
+	}
+	description, err := change(&note)
+	if err != nil {
+		return err
+	}
+	note.Id = id
+	note.Modified = time.Now().Unix()
+	note.Tags = findTags(note)
+	return writeNote(command, description, note)
+}
+
+/* Resolves title to an id under lock, then calls fn with it. */
+func byTitle(title string, fn func(id string) error) error {
+	end, err := begin()
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	id, err := GetIdFromTitle(title)
+	if err != nil {
+		return err
+	}
+	return fn(id)
+}
+
+func quote(s string) string {
+	return "'" + s + "'"
 }
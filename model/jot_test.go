@@ -0,0 +1,49 @@
+package jot
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEditConflict(t *testing.T) {
+	id := useNotes(t, "t\n - a\n")[0]
+	text := noteToString(mustGetNote(t, id))
+
+	// another process adds an item while the editor is open
+	if _, err := AddItem(id, "b", 0); err != nil {
+		t.Fatal(err)
+	}
+	err := EditNote(id, text, "t\n - a\n - c\n")
+	if !errors.Is(err, ErrEditConflict) {
+		t.Fatalf("got %v, want an edit conflict", err)
+	}
+	if note := mustGetNote(t, id); texts(note.Todo) != "a b" {
+		t.Errorf("the note should be left alone: %s", texts(note.Todo))
+	}
+}
+
+func TestEditKeepsPin(t *testing.T) {
+	id := useNotes(t, "t")[0]
+	if _, err := PinNote(id, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := EditNote(id, "t\n", "t\nmore\n"); err != nil {
+		t.Fatal(err)
+	}
+	if note := mustGetNote(t, id); !note.Pinned {
+		t.Errorf("the note should stay pinned: %+v", note)
+	}
+}
+
+func TestEditKeepsArchive(t *testing.T) {
+	id := useNotes(t, "t")[0]
+	if _, err := ArchiveNote(id, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := EditNote(id, "t\n", "t\nmore\n"); err != nil {
+		t.Fatal(err)
+	}
+	if note := mustGetNote(t, id); note.Archived == 0 {
+		t.Errorf("the note should stay archived: %+v", note)
+	}
+}
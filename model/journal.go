@@ -0,0 +1,279 @@
+package jot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+/* One recorded mutation of a note, with enough data to invert it. Before is
+ * nil when the note was created and After is nil when it was deleted. The
+ * notes are kept without their revisions, which stay as they are when an
+ * operation is undone or redone. A
+ * linked operation was made together with the one before it, e.g. to the
+ * other note of a transfer, and is undone and redone along with it. */
+type Operation struct {
+	Time        int64  `json:"time"`
+	Command     string `json:"command"`
+	Description string `json:"description"`
+	Before      *Note  `json:"before"`
+	After       *Note  `json:"after"`
+	Linked      bool   `json:"linked,omitempty"`
+}
+
+/* The operation journal. The first Position operations are applied, any
+ * after that have been undone and can be redone. Version is the format
+ * version of the notes in it. */
+type Journal struct {
+	Version    int         `json:"version"`
+	Operations []Operation `json:"operations"`
+	Position   int         `json:"position"`
+}
+
+/* How many operations are kept. */
+const journalLimit = 100
+
+var journal Journal
+var journalPath string
+
+/* Reads the journal from journalPath. A missing journal is an empty one. */
+func loadJournal() error {
+	journal = Journal{}
+	if journalPath == "" {
+		return nil
+	}
+	bytes, err := ioutil.ReadFile(journalPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	journal, err = decodeJournal(bytes)
+	return err
+}
+
+/* Decodes a journal, upgrading the notes in it like those of notes.json. */
+func decodeJournal(bytes []byte) (Journal, error) {
+	var decoded Journal
+	var doc map[string]interface{}
+	err := json.Unmarshal(bytes, &doc)
+	if err != nil {
+		return decoded, err
+	}
+	version, err := docVersion(doc)
+	if err != nil {
+		return decoded, err
+	}
+
+	// the migrations change the notes in place, inside the operations
+	rawNotes := []interface{}{}
+	rawOps, _ := doc["operations"].([]interface{})
+	for _, rawOp := range rawOps {
+		op, _ := rawOp.(map[string]interface{})
+		for _, key := range []string{"before", "after"} {
+			if note, ok := op[key].(map[string]interface{}); ok {
+				rawNotes = append(rawNotes, note)
+			}
+		}
+	}
+	err = migrate(map[string]interface{}{"notes": rawNotes}, version)
+	if err != nil {
+		return decoded, err
+	}
+
+	bytes, err = json.Marshal(doc)
+	if err != nil {
+		return decoded, err
+	}
+	err = json.Unmarshal(bytes, &decoded)
+	for _, op := range decoded.Operations {
+		for _, note := range []*Note{op.Before, op.After} {
+			if note != nil {
+				assignHandles(note)
+				// older journals kept them
+				note.Revisions = nil
+			}
+		}
+	}
+	return decoded, err
+}
+
+/* Writes the journal to journalPath, if there is one. */
+func writeJournal() error {
+	if journalPath == "" {
+		return nil
+	}
+	journal.Version = FormatVersion
+	bytes, err := json.MarshalIndent(journal, "", "    ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(journalPath, bytes, 0644)
+}
+
+/* Records a mutation, dropping any undone operations and the oldest ones
+ * past journalLimit. */
+func record(command, description string, before, after *Note) error {
+	op := Operation{
+		Time:        time.Now().Unix(),
+		Command:     command,
+		Description: description,
+		Before:      withoutRevisions(before),
+		After:       withoutRevisions(after),
+	}
+	journal.Operations = append(journal.Operations[:journal.Position], op)
+	if len(journal.Operations) > journalLimit {
+		journal.Operations = journal.Operations[len(journal.Operations)-journalLimit:]
+	}
+	journal.Position = len(journal.Operations)
+	return storageError(writeJournal())
+}
+
+/* Returns a copy of note without its revisions, nil stays nil. */
+func withoutRevisions(note *Note) *Note {
+	if note == nil {
+		return nil
+	}
+	copied := *note
+	copied.Revisions = nil
+	return &copied
+}
+
+/* Drops every operation on the note with id from the journal, so nothing of
+ * a permanently deleted note is kept. Operations linked to a dropped one
+ * stay linked to each other. */
+func forgetNote(id string) error {
+	kept := []Operation{}
+	position := 0
+	headDropped := false
+	for i, op := range journal.Operations {
+		if !op.Linked {
+			headDropped = false
+		}
+		if (op.Before != nil && op.Before.Id == id) || (op.After != nil && op.After.Id == id) {
+			headDropped = headDropped || !op.Linked
+			continue
+		}
+		// the first operation left of a group takes the place of its head
+		if op.Linked && headDropped {
+			op.Linked = false
+			headDropped = false
+		}
+		if i < journal.Position {
+			position++
+		}
+		kept = append(kept, op)
+	}
+	journal.Operations = kept
+	journal.Position = position
+	return storageError(writeJournal())
+}
+
+/* Links the last recorded operation to the one before it, see Operation. */
+func linkLastOperation() error {
+	if journal.Position < 2 {
+		return nil
+	}
+	journal.Operations[journal.Position-1].Linked = true
+	return storageError(writeJournal())
+}
+
+/* Returns the journal, oldest operation first. */
+func GetJournal() (Journal, error) {
+	end, err := begin()
+	if err != nil {
+		return Journal{}, err
+	}
+	defer end()
+	return journal, nil
+}
+
+/* Undoes the last n applied operations, newest first, counting linked
+ * operations as one. Returns the operations that were undone. */
+func Undo(n int) ([]Operation, error) {
+	end, err := begin()
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	undone := []Operation{}
+	for n > 0 && journal.Position > 0 {
+		op := journal.Operations[journal.Position-1]
+		before := op.Before
+		// like unarchive, so AutoArchive does not archive the note right again
+		if op.Command == autoArchiveCommand && before != nil {
+			unarchived := *before
+			unarchived.Unarchived = time.Now().Unix()
+			before = &unarchived
+		}
+		if err = restore(op.After, before); err != nil {
+			break
+		}
+		journal.Position--
+		undone = append(undone, op)
+		if !op.Linked {
+			n--
+		}
+	}
+	if len(undone) > 0 {
+		if journalErr := storageError(writeJournal()); err == nil {
+			err = journalErr
+		}
+	}
+	return undone, err
+}
+
+/* Redoes the next n undone operations, oldest first, counting linked
+ * operations as one. Returns the operations that were redone. */
+func Redo(n int) ([]Operation, error) {
+	end, err := begin()
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	redone := []Operation{}
+	for journal.Position < len(journal.Operations) {
+		op := journal.Operations[journal.Position]
+		if n == 0 && !op.Linked {
+			break
+		}
+		if err = restore(op.Before, op.After); err != nil {
+			break
+		}
+		journal.Position++
+		redone = append(redone, op)
+		if !op.Linked {
+			n--
+		}
+	}
+	if len(redone) > 0 {
+		if journalErr := storageError(writeJournal()); err == nil {
+			err = journalErr
+		}
+	}
+	return redone, err
+}
+
+/* Brings a note from the from state to the to state, where nil means the
+ * note does not exist. The note keeps the revisions it has now. */
+func restore(from, to *Note) error {
+	if to == nil {
+		if from == nil {
+			return nil
+		}
+		return storageError(store.Delete(from.Id))
+	}
+	note := *to
+	current, found, err := store.Get(note.Id)
+	if err != nil {
+		return storageError(err)
+	}
+	if found {
+		note.Revisions = current.Revisions
+	}
+	return storageError(store.Put(note))
+}
@@ -0,0 +1,166 @@
+package jot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUndoRedo(t *testing.T) {
+	id := useNotes(t, "t\n - a\n")[0]
+	if _, err := AddItem(id, "b", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CheckItem(id, "0", false); err != nil {
+		t.Fatal(err)
+	}
+
+	undone, err := Undo(2)
+	if err != nil || len(undone) != 2 || undone[0].Command != "check" || undone[1].Command != "add" {
+		t.Fatalf("Undo(2): got %+v, %v", undone, err)
+	}
+	if note := mustGetNote(t, id); texts(note.Todo) != "a" || len(note.Done) != 0 {
+		t.Errorf("after undo: got to-do %s and %d done", texts(note.Todo), len(note.Done))
+	}
+
+	redone, err := Redo(1)
+	if err != nil || len(redone) != 1 || redone[0].Command != "add" {
+		t.Fatalf("Redo(1): got %+v, %v", redone, err)
+	}
+	if note := mustGetNote(t, id); texts(note.Todo) != "a b" {
+		t.Errorf("after redo: got to-do %s", texts(note.Todo))
+	}
+
+	// a new change drops what is left to redo
+	if _, err = AddItem(id, "c", 0); err != nil {
+		t.Fatal(err)
+	}
+	if redone, _ = Redo(1); len(redone) != 0 {
+		t.Errorf("nothing should be left to redo: %+v", redone)
+	}
+}
+
+func TestUndoNewNote(t *testing.T) {
+	id := useNotes(t, "t")[0]
+	if _, err := Undo(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetNoteById(id); err == nil {
+		t.Error("undoing new should remove the note")
+	}
+	if _, err := Redo(1); err != nil {
+		t.Fatal(err)
+	}
+	mustGetNote(t, id)
+}
+
+func TestUndoAutoArchive(t *testing.T) {
+	id := useNotes(t, "t\n X done\n")[0]
+	archived, err := AutoArchive(0)
+	if err != nil || len(archived) != 1 {
+		t.Fatalf("AutoArchive: got %v, %v", archived, err)
+	}
+	if _, err = Undo(1); err != nil {
+		t.Fatal(err)
+	}
+	if note := mustGetNote(t, id); note.Archived != 0 || note.Unarchived == 0 {
+		t.Fatalf("undoing should take the note out of the archive and start over: %+v", note)
+	}
+	if archived, _ = AutoArchive(time.Hour); len(archived) != 0 {
+		t.Errorf("the note should get another full period, got %v archived", archived)
+	}
+}
+
+func TestUndoTransferIsOneStep(t *testing.T) {
+	ids := useNotes(t, "from\n - a\n   - a1\n - b\n", "to\n - c\n")
+	if _, err := AddItem(ids[1], "d", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TransferItem(ids[0], "0", ids[1]); err != nil {
+		t.Fatal(err)
+	}
+	from, to := mustGetNote(t, ids[0]), mustGetNote(t, ids[1])
+	if texts(from.Todo) != "b" || texts(to.Todo) != "c d a" || texts(to.Todo[2].Children) != "a1" {
+		t.Fatalf("after transfer: from %s, to %s", texts(from.Todo), texts(to.Todo))
+	}
+
+	undone, err := Undo(1)
+	if err != nil || len(undone) != 2 {
+		t.Fatalf("Undo(1) should undo both halves of the transfer: got %+v, %v", undone, err)
+	}
+	from, to = mustGetNote(t, ids[0]), mustGetNote(t, ids[1])
+	if texts(from.Todo) != "a b" || texts(to.Todo) != "c d" {
+		t.Errorf("after undo: from %s, to %s", texts(from.Todo), texts(to.Todo))
+	}
+
+	redone, err := Redo(1)
+	if err != nil || len(redone) != 2 {
+		t.Fatalf("Redo(1) should redo both halves of the transfer: got %+v, %v", redone, err)
+	}
+	if to = mustGetNote(t, ids[1]); texts(to.Todo) != "c d a" {
+		t.Errorf("after redo: to %s", texts(to.Todo))
+	}
+
+	// the add before the transfer is a step of its own
+	if undone, _ = Undo(2); len(undone) != 3 || undone[2].Command != "add" {
+		t.Errorf("Undo(2) should undo the transfer and the add: got %+v", undone)
+	}
+}
+
+func TestJournalKeepsNoRevisions(t *testing.T) {
+	id := useNotes(t, "t\n - a\n")[0]
+	text := noteToString(mustGetNote(t, id))
+	if err := EditNote(id, text, "t\n - b\n"); err != nil {
+		t.Fatal(err)
+	}
+	for _, op := range journal.Operations {
+		if (op.Before != nil && op.Before.Revisions != nil) || op.After.Revisions != nil {
+			t.Fatalf("the journal should not keep revisions: %+v", op)
+		}
+	}
+
+	if _, err := Undo(1); err != nil {
+		t.Fatal(err)
+	}
+	note := mustGetNote(t, id)
+	if texts(note.Todo) != "a" || len(note.Revisions) != 1 {
+		t.Errorf("undo should bring back a and keep the revision: %s, %+v", texts(note.Todo), note.Revisions)
+	}
+	if _, err := Redo(1); err != nil {
+		t.Fatal(err)
+	}
+	if note = mustGetNote(t, id); texts(note.Todo) != "b" || len(note.Revisions) != 1 {
+		t.Errorf("redo should bring back b and keep the revision: %s, %+v", texts(note.Todo), note.Revisions)
+	}
+}
+
+func TestEmptyTrashForgetsNotes(t *testing.T) {
+	ids := useNotes(t, "from\n - a\n", "to")
+	if _, err := TransferItem(ids[0], "0", ids[1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DeleteNote(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := EmptyTrash(0); err != nil || n != 1 {
+		t.Fatalf("EmptyTrash: got %d, %v", n, err)
+	}
+
+	for _, op := range journal.Operations {
+		for _, note := range []*Note{op.Before, op.After} {
+			if note != nil && note.Id == ids[0] {
+				t.Fatalf("the journal still holds the deleted note: %+v", op)
+			}
+		}
+	}
+	// what is left of the transfer is undone on its own, then the new notes
+	undone, err := Undo(1)
+	if err != nil || len(undone) != 1 || undone[0].Command != "transfer" {
+		t.Fatalf("Undo(1): got %+v, %v", undone, err)
+	}
+	if note := mustGetNote(t, ids[1]); len(note.Todo) != 0 {
+		t.Errorf("a should be gone from to: %s", texts(note.Todo))
+	}
+	if undone, _ = Undo(5); len(undone) != 1 || undone[0].Command != "new" {
+		t.Errorf("only the new note to should be left to undo: %+v", undone)
+	}
+}
@@ -0,0 +1,109 @@
+package jot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+/* A Store backed by a single notes.json file. Every mutation rewrites the
+ * whole file. */
+type JSONStore struct {
+	path  string
+	notes Notes
+	lock  *fileLock
+}
+
+/* Returns a JSONStore for the notes.json file at path. Call Load before use. */
+func NewJSONStore(path string) *JSONStore {
+	return &JSONStore{path: path}
+}
+
+/* Returns the path of the backing file. */
+func (s *JSONStore) Path() string {
+	return s.path
+}
+
+/* Reads notes.json, upgrading it first if it was written by an older jot.
+ * The file as it was before the upgrade is kept next to it as a backup. */
+func (s *JSONStore) Load() error {
+	bytes, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	notes, version, err := decodeNotes(bytes)
+	if err != nil {
+		return fmt.Errorf("%s: %v", s.path, err)
+	}
+	assignAllHandles(notes.Notes)
+	s.notes = notes
+	if version == FormatVersion {
+		return nil
+	}
+
+	// persist the upgrade
+	if s.lock == nil {
+		err = s.Lock()
+		if err != nil {
+			return err
+		}
+		defer s.Unlock()
+	}
+	backup := fmt.Sprintf("%s.v%d.bak", s.path, version)
+	err = writeFileAtomic(backup, bytes, 0644)
+	if err != nil {
+		return err
+	}
+	return s.write()
+}
+
+func (s *JSONStore) Get(id string) (Note, bool, error) {
+	note, found := findNote(s.notes.Notes, id)
+	return note, found, nil
+}
+
+func (s *JSONStore) Put(note Note) error {
+	s.notes.Notes = putNote(s.notes.Notes, note)
+	return s.write()
+}
+
+func (s *JSONStore) Delete(id string) error {
+	s.notes.Notes = deleteNote(s.notes.Notes, id)
+	return s.write()
+}
+
+func (s *JSONStore) List() ([]Note, error) {
+	return cloneNotes(s.notes.Notes), nil
+}
+
+/* Takes the advisory lock on path + ".lock". */
+func (s *JSONStore) Lock() error {
+	if s.lock != nil {
+		return nil
+	}
+	lock, err := acquireLock(s.path + ".lock")
+	if err != nil {
+		return err
+	}
+	s.lock = lock
+	return nil
+}
+
+func (s *JSONStore) Unlock() error {
+	if s.lock == nil {
+		return nil
+	}
+	err := s.lock.release()
+	s.lock = nil
+	return err
+}
+
+/* Writes notes to path. */
+func (s *JSONStore) write() error {
+	s.notes.Version = FormatVersion
+	bytes, err := json.MarshalIndent(s.notes, "", "    ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, bytes, 0644)
+}
@@ -0,0 +1,170 @@
+package jot
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+/* A [[link]] to another note, by id or title. */
+var linkPattern = regexp.MustCompile(`\[\[([^\[\]\n]+)\]\]`)
+
+/* A link written in a note and the note it leads to. Err is set instead of
+ * Target for broken links, when no note or more than one note matches. */
+type Link struct {
+	Ref    string // what is written between the brackets
+	Target Note
+	Err    error
+}
+
+/* A piece of text, either plain or a link. */
+type LinkSpan struct {
+	Text string
+	Link *Link
+}
+
+/* Returns the refs of the links written in text, in order. */
+func linksIn(text string) []string {
+	refs := []string{}
+	for _, match := range linkPattern.FindAllStringSubmatch(text, -1) {
+		refs = append(refs, strings.TrimSpace(match[1]))
+	}
+	return refs
+}
+
+/* Returns the note a link ref leads to: the note whose id is ref, or else
+ * the note titled ref, exactly or else ignoring case. Unlike titles given on
+ * the command line, prefixes do not match, so a link keeps leading to the
+ * same note when others are added. Archived notes can be linked to, notes in
+ * the trash can not. */
+func ResolveLink(ref string) (Note, error) {
+	note, err := GetNoteById(ref)
+	if err == nil || !errors.Is(err, ErrNoteNotFound) {
+		return note, err
+	}
+
+	matches, err := findNotesByTitle(ref, func(note Note) bool {
+		return note.Deleted == 0
+	}, false)
+	if err != nil {
+		return Note{}, err
+	}
+	switch len(matches) {
+	case 0:
+		return Note{}, titleNotFound(ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return Note{}, &AmbiguousTitleError{Title: ref, Matches: matches}
+	}
+}
+
+/* Splits text into plain text and resolved links, for display. */
+func SplitLinks(text string) []LinkSpan {
+	spans := []LinkSpan{}
+	last := 0
+	for _, match := range linkPattern.FindAllStringSubmatchIndex(text, -1) {
+		if match[0] > last {
+			spans = append(spans, LinkSpan{Text: text[last:match[0]]})
+		}
+		link := resolveLink(strings.TrimSpace(text[match[2]:match[3]]))
+		spans = append(spans, LinkSpan{Text: text[match[0]:match[1]], Link: &link})
+		last = match[1]
+	}
+	if last < len(text) {
+		spans = append(spans, LinkSpan{Text: text[last:]})
+	}
+	return spans
+}
+
+func resolveLink(ref string) Link {
+	target, err := ResolveLink(ref)
+	return Link{Ref: ref, Target: target, Err: err}
+}
+
+/* Returns the refs of every link written in the lines and items of note. */
+func noteLinks(note Note) []string {
+	refs := []string{}
+	for _, line := range note.Lines {
+		refs = append(refs, linksIn(line)...)
+	}
+	collect := func(item *Item) {
+		refs = append(refs, linksIn(item.Text)...)
+	}
+	walkItems(note.Todo, collect)
+	walkItems(note.Done, collect)
+	return refs
+}
+
+/* Returns the links written in the note with id, resolved. */
+func GetLinks(id string) ([]Link, error) {
+	note, err := GetNoteById(id)
+	if err != nil {
+		return nil, err
+	}
+	links := []Link{}
+	for _, ref := range noteLinks(note) {
+		links = append(links, resolveLink(ref))
+	}
+	return links, nil
+}
+
+/* Returns the notes that link to the note with id, archived ones included,
+ * in the order they are stored. */
+func GetBacklinks(id string) (Notes, error) {
+	if _, err := GetNoteById(id); err != nil {
+		return Notes{}, err
+	}
+	notes, err := listNotes()
+	if err != nil {
+		return Notes{}, err
+	}
+	linking := Notes{Notes: []Note{}}
+	targets := map[string]string{} // ref to the id it leads to, "" if none
+	for _, note := range notes {
+		if note.Deleted != 0 || note.Id == id {
+			continue
+		}
+		for _, ref := range noteLinks(note) {
+			target, known := targets[ref]
+			if !known {
+				if linked, err := ResolveLink(ref); err == nil {
+					target = linked.Id
+				}
+				targets[ref] = target
+			}
+			if target == id {
+				linking.Notes = append(linking.Notes, note)
+				break
+			}
+		}
+	}
+	return linking, nil
+}
+
+/* A link that leads nowhere and the note it is written in. */
+type BrokenLink struct {
+	Note Note
+	Link Link
+}
+
+/* Returns every broken link in the notes that are not in the trash, in the
+ * order they are stored. */
+func GetBrokenLinks() ([]BrokenLink, error) {
+	notes, err := listNotes()
+	if err != nil {
+		return nil, err
+	}
+	broken := []BrokenLink{}
+	for _, note := range notes {
+		if note.Deleted != 0 {
+			continue
+		}
+		for _, ref := range noteLinks(note) {
+			if link := resolveLink(ref); link.Err != nil {
+				broken = append(broken, BrokenLink{note, link})
+			}
+		}
+	}
+	return broken, nil
+}
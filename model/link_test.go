@@ -0,0 +1,46 @@
+package jot
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResolveLink(t *testing.T) {
+	ids := useNotes(t, "Meeting notes", "Plans", "plans")
+
+	tests := []struct {
+		ref  string
+		want string
+		err  error
+	}{
+		{ids[0], ids[0], nil},
+		{"Meeting notes", ids[0], nil},
+		{"meeting NOTES", ids[0], nil},
+		{"Plans", ids[1], nil},
+		{"PLANS", "", ErrAmbiguousTitle},
+		{"meet", "", ErrNoteNotFound},
+		{ids[0][:8], "", ErrNoteNotFound},
+	}
+	for _, test := range tests {
+		note, err := ResolveLink(test.ref)
+		if !errors.Is(err, test.err) || note.Id != test.want {
+			t.Errorf("ResolveLink(%q): got %s, %v, want %s, %v", test.ref, note.Id, err, test.want, test.err)
+		}
+	}
+}
+
+func TestBacklinks(t *testing.T) {
+	ids := useNotes(t, "target", "a\nsee [[target]]", "b\n - ask [[TARGET]]", "c\n[[targets]]")
+
+	linking, err := GetBacklinks(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(linking.Notes) != 2 || linking.Notes[0].Id != ids[1] || linking.Notes[1].Id != ids[2] {
+		t.Errorf("got %+v, want a and b", linking.Notes)
+	}
+	broken, err := GetBrokenLinks()
+	if err != nil || len(broken) != 1 || broken[0].Note.Id != ids[3] {
+		t.Errorf("got %+v, %v, want the link of c", broken, err)
+	}
+}
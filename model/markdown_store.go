@@ -0,0 +1,311 @@
+package jot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/* A Store keeping every note in its own Markdown file, <id>.md, inside a
+ * directory. Each file starts with YAML front matter holding everything but
+ * the body; the body uses the same " - " / " X " syntax as edit. The front
+ * matter keeps the text and the rest of each checklist item, matched up with
+ * the body by text. */
+type MarkdownStore struct {
+	dir   string
+	notes []Note
+	lock  *fileLock
+}
+
+const frontMatterFence = "---"
+
+/* Keys of a note that live in the Markdown body instead of the front matter. */
+var bodyKeys = map[string]bool{"lines": true}
+
+/* Keys of a checklist item that live in the Markdown body. The text is kept
+ * in the front matter as well, to match items up with the body. */
+var itemBodyKeys = map[string]bool{"checked": true, "due": true, "priority": true, "repeat": true}
+
+/* The first format version with item texts in the front matter. */
+const frontMatterTextVersion = 12
+
+/* Returns a MarkdownStore for the directory dir. Call Load before use. */
+func NewMarkdownStore(dir string) *MarkdownStore {
+	return &MarkdownStore{dir: dir}
+}
+
+/* Returns the directory holding the note files. */
+func (s *MarkdownStore) Dir() string {
+	return s.dir
+}
+
+/* Reads every note file in the directory, creating the directory if needed. */
+func (s *MarkdownStore) Load() error {
+	err := os.MkdirAll(s.dir, 0755)
+	if err != nil {
+		return err
+	}
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.md"))
+	if err != nil {
+		return err
+	}
+
+	notes := []Note{}
+	for _, path := range paths {
+		bytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		note, err := decodeMarkdownNote(bytes)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		notes = append(notes, note)
+	}
+
+	// oldest first, xids sort by creation as well
+	sort.SliceStable(notes, func(i, j int) bool {
+		if notes[i].Created != notes[j].Created {
+			return notes[i].Created < notes[j].Created
+		}
+		return notes[i].Id < notes[j].Id
+	})
+	s.notes = notes
+	return nil
+}
+
+func (s *MarkdownStore) Get(id string) (Note, bool, error) {
+	note, found := findNote(s.notes, id)
+	return note, found, nil
+}
+
+func (s *MarkdownStore) Put(note Note) error {
+	bytes, err := encodeMarkdownNote(note)
+	if err != nil {
+		return err
+	}
+	err = writeFileAtomic(s.notePath(note.Id), bytes, 0644)
+	if err != nil {
+		return err
+	}
+	s.notes = putNote(s.notes, note)
+	return nil
+}
+
+func (s *MarkdownStore) Delete(id string) error {
+	err := os.Remove(s.notePath(id))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.notes = deleteNote(s.notes, id)
+	return nil
+}
+
+func (s *MarkdownStore) List() ([]Note, error) {
+	return cloneNotes(s.notes), nil
+}
+
+/* Takes the advisory lock on dir/.lock. */
+func (s *MarkdownStore) Lock() error {
+	if s.lock != nil {
+		return nil
+	}
+	err := os.MkdirAll(s.dir, 0755)
+	if err != nil {
+		return err
+	}
+	lock, err := acquireLock(filepath.Join(s.dir, ".lock"))
+	if err != nil {
+		return err
+	}
+	s.lock = lock
+	return nil
+}
+
+func (s *MarkdownStore) Unlock() error {
+	if s.lock == nil {
+		return nil
+	}
+	err := s.lock.release()
+	s.lock = nil
+	return err
+}
+
+func (s *MarkdownStore) notePath(id string) string {
+	return filepath.Join(s.dir, id+".md")
+}
+
+/* Renders note as front matter followed by its body. The front matter keeps
+ * the field order and keys of notes.json. */
+func encodeMarkdownNote(note Note) ([]byte, error) {
+	jsonBytes, err := json.Marshal(note)
+	if err != nil {
+		return nil, err
+	}
+	// json is yaml, decoding into a node keeps the field order
+	var doc yaml.Node
+	err = yaml.Unmarshal(jsonBytes, &doc)
+	if err != nil {
+		return nil, err
+	}
+	mapping := doc.Content[0]
+	content := []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: "version"},
+		{Kind: yaml.ScalarNode, Tag: "!!int", Value: fmt.Sprint(FormatVersion)},
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i].Value, mapping.Content[i+1]
+		if bodyKeys[key] {
+			continue
+		}
+		if key == "to-do" || key == "done" {
+			stripItems(value)
+		}
+		content = append(content, mapping.Content[i], value)
+	}
+	mapping.Content = content
+	blockStyle(&doc)
+
+	frontMatter, err := yaml.Marshal(&doc)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(frontMatterFence + "\n" + string(frontMatter) + frontMatterFence + "\n" + bodyToString(note)), nil
+}
+
+/* Parses a note file written by encodeMarkdownNote, upgrading front matter
+ * written by older versions of jot. */
+func decodeMarkdownNote(data []byte) (note Note, err error) {
+	text := strings.Replace(string(data), "\r\n", "\n", -1)
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	if !strings.HasPrefix(text, frontMatterFence+"\n") {
+		return note, fmt.Errorf("missing front matter")
+	}
+	text = text[len(frontMatterFence)+1:]
+	end := strings.Index(text, "\n"+frontMatterFence+"\n")
+	if end == -1 {
+		return note, fmt.Errorf("unterminated front matter")
+	}
+	frontMatter, body := text[:end+1], text[end+len(frontMatterFence)+2:]
+
+	fields := map[string]interface{}{}
+	err = yaml.Unmarshal([]byte(frontMatter), &fields)
+	if err != nil {
+		return
+	}
+
+	// run the front matter through the same migrations as notes.json
+	doc := map[string]interface{}{"notes": []interface{}{fields}}
+	if version, ok := fields["version"]; ok {
+		doc["version"] = version
+		delete(fields, "version")
+	}
+	jsonBytes, err := json.Marshal(doc)
+	if err != nil {
+		return
+	}
+	notes, version, err := decodeNotes(jsonBytes)
+	if err != nil {
+		return
+	}
+	if len(notes.Notes) != 1 {
+		return note, fmt.Errorf("invalid front matter")
+	}
+
+	note = notes.Notes[0]
+	todo, done := note.Todo, note.Done
+	parseBody(&note, splitLines(body))
+	if version < frontMatterTextVersion {
+		note.Todo = mergeItemsByPosition(todo, note.Todo, note.Created)
+		note.Done = mergeItemsByPosition(done, note.Done, note.Created)
+	} else {
+		mergeItems(&note, todo, done)
+	}
+	assignHandles(&note)
+	// the body may have been changed by hand
+	note.Tags = findTags(note)
+	return note, nil
+}
+
+/* Gives the items parsed from the body of note the handle and times of the
+ * front matter items todo and done with the same text, so items added,
+ * removed or moved in the body by hand do not take over the handles of
+ * others. Items without a match were added by hand and count as created with
+ * the note. */
+func mergeItems(note *Note, todo, done []Item) {
+	lists := [][]Item{note.Todo, note.Done}
+	for _, items := range lists {
+		walkItems(items, func(item *Item) {
+			item.CreatedAt = note.Created
+		})
+	}
+	matchItems([][]Item{todo, done}, lists, func(item *Item, previous Item) {
+		item.Handle = previous.Handle
+		item.CreatedAt = previous.CreatedAt
+		// the state is only in the body, a completion time only for done items
+		if item.Checked {
+			item.CompletedAt = previous.CompletedAt
+		}
+	})
+}
+
+/* Returns the items parsed from a body with the rest of their fields taken
+ * from the front matter items at the same position, sub-items likewise, for
+ * front matter without item texts. Items added to the body by hand have no
+ * front matter and count as created at created. */
+func mergeItemsByPosition(front, body []Item, created int64) []Item {
+	for i := range body {
+		if i < len(front) {
+			parsed := body[i]
+			body[i] = front[i]
+			body[i].Text, body[i].Checked, body[i].Due = parsed.Text, parsed.Checked, parsed.Due
+			body[i].Priority, body[i].Repeat = parsed.Priority, parsed.Repeat
+			body[i].Children = mergeItemsByPosition(front[i].Children, parsed.Children, created)
+		} else {
+			body[i].CreatedAt = created
+			body[i].Children = mergeItemsByPosition(nil, body[i].Children, created)
+		}
+	}
+	return body
+}
+
+/* Drops the keys kept in the body from a yaml sequence of items and their
+ * sub-items. */
+func stripItems(items *yaml.Node) {
+	for _, item := range items.Content {
+		item.Content = withoutKeys(item.Content, itemBodyKeys)
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			if item.Content[i].Value == "children" {
+				stripItems(item.Content[i+1])
+			}
+		}
+	}
+}
+
+/* Returns the key value pairs of a yaml mapping without the given keys. */
+func withoutKeys(pairs []*yaml.Node, keys map[string]bool) []*yaml.Node {
+	kept := []*yaml.Node{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if !keys[pairs[i].Value] {
+			kept = append(kept, pairs[i], pairs[i+1])
+		}
+	}
+	return kept
+}
+
+/* Clears the flow style json leaves on a yaml node tree. */
+func blockStyle(node *yaml.Node) {
+	// strings that would read back as another type stay quoted by yaml
+	node.Style &^= yaml.FlowStyle | yaml.DoubleQuotedStyle
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
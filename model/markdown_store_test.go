@@ -0,0 +1,81 @@
+package jot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	note := parseNote("trip #travel\npack light\n - passport !1 @due(2026-11-01)\n   - renew\n X tickets\n - plants @every(week)\n")
+	assignHandles(&note)
+	bytes, err := encodeMarkdownNote(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := decodeMarkdownNote(bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, note) {
+		t.Errorf("got %+v, want %+v", decoded, note)
+	}
+}
+
+func TestMarkdownItemsAddedByHandKeepHandles(t *testing.T) {
+	note := parseNote("t\n - alpha\n - beta\n X gamma\n")
+	assignHandles(&note)
+	bytes, err := encodeMarkdownNote(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// insert an item above alpha and check beta by hand
+	text := strings.Replace(string(bytes), " - alpha\n", " - inserted\n - alpha\n", 1)
+	text = strings.Replace(text, " - beta\n", " X beta\n", 1)
+	decoded, err := decodeMarkdownNote([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handles := map[string]string{}
+	completed := map[string]int64{}
+	walkItems(append(decoded.Todo, decoded.Done...), func(item *Item) {
+		handles[item.Text] = item.Handle
+		completed[item.Text] = item.CompletedAt
+	})
+	for i, want := range []Item{note.Todo[0], note.Todo[1], note.Done[0]} {
+		if handles[want.Text] != want.Handle {
+			t.Errorf("item %d, %s: got handle %s, want %s", i, want.Text, handles[want.Text], want.Handle)
+		}
+	}
+	if handle := handles["inserted"]; handle == "" || handle == note.Todo[0].Handle {
+		t.Errorf("the inserted item should get a new handle, got %q", handle)
+	}
+	if completed["gamma"] != note.Done[0].CompletedAt {
+		t.Errorf("gamma should keep its completion time, got %d", completed["gamma"])
+	}
+}
+
+func TestMarkdownVersion11MatchesByPosition(t *testing.T) {
+	file := `---
+version: 11
+id: bngre9ku76li6v1ts97g
+title: t
+created: 100
+modified: 100
+to-do:
+    - handle: abc
+      created-at: 50
+done: []
+---
+ - alpha
+`
+	note, err := decodeMarkdownNote([]byte(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item := note.Todo[0]; item.Handle != "abc" || item.CreatedAt != 50 {
+		t.Errorf("got %+v, want the front matter of the first item", item)
+	}
+}
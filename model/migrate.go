@@ -0,0 +1,264 @@
+package jot
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+/* The format version of the notes document this jot reads and writes.
+ * Bump it together with a new entry in migrations whenever the on-disk shape
+ * of Notes or Note changes, and bring notes_default.json up to it so new
+ * installs do not start out migrating. */
+const FormatVersion = 12
+
+/* A migration upgrades a raw notes document by exactly one version. */
+type migration func(doc map[string]interface{}) error
+
+/* migrations[i] upgrades a document from version i to version i+1.
+ * Migrations change raw notes in place, the journal relies on it. Notes read
+ * from Markdown front matter have no lines, to-do or done. */
+var migrations = []migration{
+	migrateV0,
+	migrateV1,
+	migrateV2,
+	migrateV3,
+	migrateV4,
+	migrateV5,
+	migrateV6,
+	migrateV7,
+	migrateV8,
+	migrateV9,
+	migrateV10,
+	migrateV11,
+}
+
+/* Decodes a notes document of any known version, upgrading it to
+ * FormatVersion. Returns the version the document was in. */
+func decodeNotes(bytes []byte) (notes Notes, version int, err error) {
+	var doc map[string]interface{}
+	err = json.Unmarshal(bytes, &doc)
+	if err != nil {
+		return
+	}
+
+	version, err = docVersion(doc)
+	if err != nil {
+		return
+	}
+	err = migrate(doc, version)
+	if err != nil {
+		return
+	}
+
+	bytes, err = json.Marshal(doc)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bytes, &notes)
+	return
+}
+
+/* Runs every migration needed to bring doc from version up to FormatVersion. */
+func migrate(doc map[string]interface{}, version int) error {
+	if version > FormatVersion {
+		return fmt.Errorf("notes are in format %d but this jot only understands up to format %d, please upgrade jot", version, FormatVersion)
+	}
+	for v := version; v < FormatVersion; v++ {
+		err := migrations[v](doc)
+		if err != nil {
+			return fmt.Errorf("migrating notes from format %d to %d: %v", v, v+1, err)
+		}
+		doc["version"] = v + 1
+	}
+	return nil
+}
+
+/* Returns the version of a raw document, documents without one are version 0. */
+func docVersion(doc map[string]interface{}) (int, error) {
+	raw, ok := doc["version"]
+	if !ok {
+		return 0, nil
+	}
+	version, ok := raw.(float64)
+	if !ok || version < 0 || version != float64(int(version)) {
+		return 0, fmt.Errorf("invalid notes format version: %v", raw)
+	}
+	return int(version), nil
+}
+
+/* Calls fn on every raw note in doc. */
+func eachNote(doc map[string]interface{}, fn func(note map[string]interface{}) error) error {
+	rawNotes, _ := doc["notes"].([]interface{})
+	for _, rawNote := range rawNotes {
+		note, ok := rawNote.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid note: %v", rawNote)
+		}
+		err := fn(note)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* Calls fn on every raw checklist item of a raw note, sub-items included. */
+func eachItem(note map[string]interface{}, fn func(item map[string]interface{})) {
+	var walk func(items interface{})
+	walk = func(items interface{}) {
+		list, _ := items.([]interface{})
+		for _, item := range list {
+			if item, ok := item.(map[string]interface{}); ok {
+				fn(item)
+				walk(item["children"])
+			}
+		}
+	}
+	walk(note["to-do"])
+	walk(note["done"])
+}
+
+/* Version 0 had no version field and allowed null lists. */
+func migrateV0(doc map[string]interface{}) error {
+	if doc["notes"] == nil {
+		doc["notes"] = []interface{}{}
+	}
+	return eachNote(doc, func(note map[string]interface{}) error {
+		for _, key := range []string{"lines", "to-do", "done"} {
+			if note[key] == nil {
+				note[key] = []interface{}{}
+			}
+		}
+		return nil
+	})
+}
+
+/* Version 1 had a single time for each note and checklist items were plain
+ * strings. The time becomes the creation and modification time of the note
+ * and its items. When done items were completed is unknown. */
+func migrateV1(doc map[string]interface{}) error {
+	return eachNote(doc, func(note map[string]interface{}) error {
+		created := note["time"]
+		if created == nil {
+			created = 0
+		}
+		delete(note, "time")
+		note["created"] = created
+		note["modified"] = created
+
+		for _, key := range []string{"to-do", "done"} {
+			items, _ := note[key].([]interface{})
+			for i, item := range items {
+				if text, ok := item.(string); ok {
+					items[i] = map[string]interface{}{"text": text, "created-at": created}
+				}
+			}
+		}
+		return nil
+	})
+}
+
+/* Version 2 items had no handle and their state was only the list they were
+ * in. Handles are assigned by the stores once the notes are decoded, as
+ * Markdown front matter alone does not have the item texts they depend on. */
+func migrateV2(doc map[string]interface{}) error {
+	return eachNote(doc, func(note map[string]interface{}) error {
+		items, _ := note["done"].([]interface{})
+		for _, item := range items {
+			if item, ok := item.(map[string]interface{}); ok {
+				item["checked"] = true
+			}
+		}
+		return nil
+	})
+}
+
+/* Version 3 items had no sub-items, nothing needs to change. */
+func migrateV3(doc map[string]interface{}) error {
+	return nil
+}
+
+/* Version 4 kept due dates in the item text. */
+func migrateV4(doc map[string]interface{}) error {
+	return eachNote(doc, func(note map[string]interface{}) error {
+		eachItem(note, func(item map[string]interface{}) {
+			text, _ := item["text"].(string)
+			if text, due := takeDue(text); due != "" {
+				item["text"] = text
+				item["due"] = due
+			}
+		})
+		return nil
+	})
+}
+
+/* Version 5 kept priorities in the item text. */
+func migrateV5(doc map[string]interface{}) error {
+	return eachNote(doc, func(note map[string]interface{}) error {
+		eachItem(note, func(item map[string]interface{}) {
+			text, _ := item["text"].(string)
+			if text, priority := takePriority(text); priority != 0 {
+				item["text"] = text
+				item["priority"] = priority
+			}
+		})
+		return nil
+	})
+}
+
+/* Version 6 had no tags, they are found in the title, lines and items. */
+func migrateV6(doc map[string]interface{}) error {
+	return eachNote(doc, func(note map[string]interface{}) error {
+		title, _ := note["title"].(string)
+		tags := tagsIn(title)
+		lines, _ := note["lines"].([]interface{})
+		for _, line := range lines {
+			line, _ := line.(string)
+			tags = append(tags, tagsIn(line)...)
+		}
+		eachItem(note, func(item map[string]interface{}) {
+			text, _ := item["text"].(string)
+			tags = append(tags, tagsIn(text)...)
+		})
+		if tags = uniqueTags(tags); len(tags) > 0 {
+			note["tags"] = tags
+		}
+		return nil
+	})
+}
+
+/* Version 7 had no notebooks, nothing needs to change. */
+func migrateV7(doc map[string]interface{}) error {
+	return nil
+}
+
+/* Version 8 had no pinned notes, nothing needs to change. */
+func migrateV8(doc map[string]interface{}) error {
+	return nil
+}
+
+/* Version 9 had no archive, nothing needs to change. */
+func migrateV9(doc map[string]interface{}) error {
+	return nil
+}
+
+/* Version 10 kept recurrence rules in the item text. */
+func migrateV10(doc map[string]interface{}) error {
+	return eachNote(doc, func(note map[string]interface{}) error {
+		eachItem(note, func(item map[string]interface{}) {
+			text, _ := item["text"].(string)
+			if text, rule := takeRepeat(text); rule != "" {
+				item["text"] = text
+				item["repeat"] = rule
+			}
+		})
+		return nil
+	})
+}
+
+/* Version 11 Markdown front matter had no item texts, its items are matched
+ * up with the body by position, see decodeMarkdownNote. Nothing needs to
+ * change. */
+func migrateV11(doc map[string]interface{}) error {
+	return nil
+}
@@ -0,0 +1,73 @@
+package jot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeNotesFromVersion0(t *testing.T) {
+	doc := `{"notes": [{
+		"id": "bngre9ku76li6v1ts97g",
+		"title": "jot #dev",
+		"time": 1575073574,
+		"lines": null,
+		"to-do": ["report !1 @due(2026-10-20)", "timesheet @every(week)"],
+		"done": ["settings"]
+	}]}`
+	notes, version, err := decodeNotes([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 0 {
+		t.Errorf("got version %d, want 0", version)
+	}
+	note := notes.Notes[0]
+	if note.Created != 1575073574 || note.Modified != 1575073574 {
+		t.Errorf("got created %d and modified %d, want the old time", note.Created, note.Modified)
+	}
+	if note.Lines == nil || len(note.Lines) != 0 {
+		t.Errorf("got lines %#v, want none", note.Lines)
+	}
+	if !reflect.DeepEqual(note.Tags, []string{"dev"}) {
+		t.Errorf("got tags %v, want [dev]", note.Tags)
+	}
+
+	want := []Item{
+		{Text: "report", CreatedAt: 1575073574, Due: "2026-10-20", Priority: 1},
+		{Text: "timesheet", CreatedAt: 1575073574, Repeat: "week"},
+	}
+	if !reflect.DeepEqual(note.Todo, want) {
+		t.Errorf("got to-do %+v, want %+v", note.Todo, want)
+	}
+	done := []Item{{Text: "settings", Checked: true, CreatedAt: 1575073574}}
+	if !reflect.DeepEqual(note.Done, done) {
+		t.Errorf("got done %+v, want %+v", note.Done, done)
+	}
+}
+
+func TestDecodeNotesCurrentVersionUnchanged(t *testing.T) {
+	notes, version, err := decodeNotes(defaultNotes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != FormatVersion {
+		t.Errorf("notes_default.json is in format %d, want %d", version, FormatVersion)
+	}
+	if len(notes.Notes) == 0 || notes.Notes[0].Todo[0].Handle == "" {
+		t.Error("notes_default.json should hold notes with handles")
+	}
+}
+
+func TestDecodeNotesFromTheFuture(t *testing.T) {
+	_, _, err := decodeNotes([]byte(`{"version": 1000, "notes": []}`))
+	if err == nil || !strings.Contains(err.Error(), "upgrade jot") {
+		t.Errorf("got %v, want an error asking to upgrade", err)
+	}
+}
+
+func TestMigrationsCoverEveryVersion(t *testing.T) {
+	if len(migrations) != FormatVersion {
+		t.Errorf("there are %d migrations for format version %d", len(migrations), FormatVersion)
+	}
+}
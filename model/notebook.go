@@ -0,0 +1,132 @@
+package jot
+
+import (
+	"sort"
+	"strings"
+)
+
+/* A notebook and what is in it, its sub-notebooks included. Notes and
+ * OpenItems count the notes in it and their unchecked items. */
+type Notebook struct {
+	Name      string // the last part of Path
+	Path      string // e.g. work/clients/acme, "" for the root of all notes
+	Notes     int
+	OpenItems int
+	Children  []Notebook
+}
+
+/* Cleans up a notebook path as given by the user: parts are trimmed and
+ * empty parts dropped, so " work//acme/" is work/acme and "/" is no
+ * notebook. */
+func CleanNotebook(path string) string {
+	parts := []string{}
+	for _, part := range strings.Split(path, "/") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+/* Returns whether note is in the notebook path or one of its sub-notebooks. */
+func (note Note) InNotebook(path string) bool {
+	path = CleanNotebook(path)
+	return path == "" || note.Notebook == path || strings.HasPrefix(note.Notebook, path+"/")
+}
+
+/* Given an id, move the note with this id to the notebook path, "" being no
+ * notebook. Return its title. */
+func MoveNote(id, path string) (title string, err error) {
+	end, err := begin()
+	if err != nil {
+		return "", err
+	}
+	defer end()
+
+	note, err := GetNoteById(id)
+	if err != nil {
+		return "", err
+	}
+	note.Notebook = CleanNotebook(path)
+	// like the trash, moving a note does not modify it
+	return note.Title, writeNote("mv", "to "+notebookName(note.Notebook), note)
+}
+
+func MoveNoteByTitle(title, path string) (id string, err error) {
+	err = byTitle(title, func(noteId string) error {
+		id = noteId
+		_, err := MoveNote(noteId, path)
+		return err
+	})
+	return
+}
+
+/* Returns every notebook of the notes that are not in the trash as a tree
+ * under a root holding all notes. Notebooks are ordered by name. */
+func GetNotebooks() (Notebook, error) {
+	notes, err := listNotes()
+	if err != nil {
+		return Notebook{}, err
+	}
+	root := Notebook{}
+	for _, note := range live(notes) {
+		open := 0
+		count := func(item *Item) {
+			if !item.Checked {
+				open++
+			}
+		}
+		walkItems(note.Todo, count)
+		walkItems(note.Done, count)
+
+		notebook := &root
+		notebook.Notes++
+		notebook.OpenItems += open
+		for _, name := range splitNotebook(note.Notebook) {
+			notebook = childNotebook(notebook, name)
+			notebook.Notes++
+			notebook.OpenItems += open
+		}
+	}
+	sortNotebooks(&root)
+	return root, nil
+}
+
+/* Returns the sub-notebook of notebook called name, adding it if needed. */
+func childNotebook(notebook *Notebook, name string) *Notebook {
+	for i := range notebook.Children {
+		if notebook.Children[i].Name == name {
+			return &notebook.Children[i]
+		}
+	}
+	path := name
+	if notebook.Path != "" {
+		path = notebook.Path + "/" + name
+	}
+	notebook.Children = append(notebook.Children, Notebook{Name: name, Path: path})
+	return &notebook.Children[len(notebook.Children)-1]
+}
+
+func sortNotebooks(notebook *Notebook) {
+	sort.Slice(notebook.Children, func(i, j int) bool {
+		return notebook.Children[i].Name < notebook.Children[j].Name
+	})
+	for i := range notebook.Children {
+		sortNotebooks(&notebook.Children[i])
+	}
+}
+
+func splitNotebook(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+/* Names a notebook path in messages. */
+func notebookName(path string) string {
+	if path == "" {
+		return "no notebook"
+	}
+	return quote(path)
+}
@@ -0,0 +1,146 @@
+package jot
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var repeatAnnotation = regexp.MustCompile(`\s*@every\(([^()]*)\)`)
+
+var repeatInterval = regexp.MustCompile(`^(\d+)\s*(day|week|month)s?$`)
+
+/* Splits a valid @every annotation off text. */
+func takeRepeat(text string) (rest, rule string) {
+	for _, match := range repeatAnnotation.FindAllStringSubmatchIndex(text, -1) {
+		if rule, ok := parseRepeat(text[match[2]:match[3]]); ok {
+			return strings.TrimSpace(text[:match[0]] + text[match[1]:]), rule
+		}
+	}
+	return text, ""
+}
+
+/* Parses a recurrence rule as written in @every(...): day, week, month, a
+ * weekday such as monday or mon, or an interval such as 3 days or 2 weeks.
+ * Returns the rule in a normal form, e.g. "day" for "daily" and "1 day". */
+func parseRepeat(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "day", "daily":
+		return "day", true
+	case "week", "weekly":
+		return "week", true
+	case "month", "monthly":
+		return "month", true
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		if s == name || s == name[:3] {
+			return name, true
+		}
+	}
+	if match := repeatInterval.FindStringSubmatch(s); match != nil {
+		n, err := strconv.Atoi(match[1])
+		switch {
+		case err != nil || n < 1:
+			return "", false
+		case n == 1:
+			return match[2], true
+		default:
+			return strconv.Itoa(n) + " " + match[2] + "s", true
+		}
+	}
+	return "", false
+}
+
+/* Describes a recurrence rule, e.g. "every Monday" or "every 3 days". */
+func RepeatString(rule string) string {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if rule == strings.ToLower(day.String()) {
+			return "every " + day.String()
+		}
+	}
+	return "every " + rule
+}
+
+/* Returns the date on which rule comes round for the steps-th time after
+ * date. Monthly rules keep the day of the month, or take the last day of
+ * months too short for it, so an item due on January 31 is next due on the
+ * last day of February. */
+func repeatStep(rule string, date time.Time, steps int) time.Time {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if rule == strings.ToLower(day.String()) {
+			return date.AddDate(0, 0, (int(day-date.Weekday())+6)%7+1+7*(steps-1))
+		}
+	}
+	n := 1
+	if fields := strings.Fields(rule); len(fields) == 2 {
+		n, _ = strconv.Atoi(fields[0])
+		rule = strings.TrimSuffix(fields[1], "s")
+	}
+	switch rule {
+	case "week":
+		return date.AddDate(0, 0, 7*n*steps)
+	case "month":
+		return addMonths(date, n*steps)
+	default:
+		return date.AddDate(0, 0, n*steps)
+	}
+}
+
+/* Returns date n months later, on the last day of the month if it is
+ * shorter than the day of date. */
+func addMonths(date time.Time, n int) time.Time {
+	first := time.Date(date.Year(), date.Month()+time.Month(n), 1, 0, 0, 0, 0, date.Location())
+	day := date.Day()
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, date.Location())
+}
+
+/* Returns the due date, YYYY-MM-DD, of the next occurrence of an item with
+ * rule that was due on due, or today if it had no due date. The next
+ * occurrence keeps the rhythm of the rule, is at least one step after due,
+ * so checking an item early moves it on too, and is always after today. */
+func nextDue(rule, due string, now time.Time) string {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	start, err := time.ParseInLocation(DateLayout, due, now.Location())
+	if err != nil {
+		start = today
+	}
+	next := repeatStep(rule, start, 1)
+	for steps := 2; !next.After(today); steps++ {
+		next = repeatStep(rule, start, steps)
+	}
+	return next.Format(DateLayout)
+}
+
+/* Checks the recurring item at path in list of note. A checked copy of the
+ * item, without the rule, logs the completion: it goes to the done list, or
+ * for a sub-item next to it. The item itself stays open with its sub-items
+ * unchecked, due on its next occurrence. */
+func recur(note *Note, list *[]Item, path []int, children bool, now time.Time) {
+	item := itemAt(*list, path)
+	logged := cloneItems([]Item{*item})
+	walkItems(logged, func(item *Item) {
+		item.Handle = ""
+	})
+	completed := logged[0]
+	completed.Repeat = ""
+	setChecked(&completed, true, children, now.Unix())
+
+	item.Due = nextDue(item.Repeat, item.Due, now)
+	item.CreatedAt = now.Unix()
+	for i := range item.Children {
+		setChecked(&item.Children[i], false, true, 0)
+	}
+
+	if len(path) == 1 {
+		note.Done = append(note.Done, completed)
+	} else {
+		insertItemAt(list, path, completed)
+	}
+	assignHandles(note)
+}
@@ -0,0 +1,73 @@
+package jot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextDue(t *testing.T) {
+	// a Sunday
+	now := time.Date(2026, 10, 18, 9, 30, 0, 0, time.Local)
+
+	tests := []struct {
+		rule, due, want string
+	}{
+		{"day", "", "2026-10-19"},
+		{"day", "2026-10-18", "2026-10-19"},
+		{"day", "2026-10-10", "2026-10-19"},
+		{"3 days", "2026-10-10", "2026-10-19"},
+		{"week", "2026-10-25", "2026-11-01"}, // checked early
+		{"week", "2026-10-11", "2026-10-25"},
+		{"2 weeks", "2026-10-01", "2026-10-29"},
+		{"friday", "", "2026-10-23"},
+		{"friday", "2026-10-23", "2026-10-30"},
+		{"sunday", "2026-10-18", "2026-10-25"},
+		{"month", "2026-10-18", "2026-11-18"},
+		{"month", "2026-10-31", "2026-11-30"},
+		{"month", "2026-01-31", "2026-10-31"},
+		{"month", "2027-01-31", "2027-02-28"},
+		{"month", "2028-01-31", "2028-02-29"},
+		{"2 months", "2026-12-31", "2027-02-28"},
+	}
+	for _, test := range tests {
+		if got := nextDue(test.rule, test.due, now); got != test.want {
+			t.Errorf("nextDue(%q, %q): got %s, want %s", test.rule, test.due, got, test.want)
+		}
+	}
+}
+
+func TestParseRepeat(t *testing.T) {
+	tests := map[string]string{
+		"daily":    "day",
+		"1 day":    "day",
+		"Weekly":   "week",
+		"fri":      "friday",
+		"3 days":   "3 days",
+		"2 week":   "2 weeks",
+		"0 days":   "",
+		"fortnite": "",
+	}
+	for given, want := range tests {
+		if got, _ := parseRepeat(given); got != want {
+			t.Errorf("parseRepeat(%q): got %q, want %q", given, got, want)
+		}
+	}
+}
+
+func TestCheckRecurringItem(t *testing.T) {
+	id := useNotes(t, "work\n - timesheet @every(week) @due(2030-01-04)\n")[0]
+
+	if _, err := CheckItem(id, "0", false); err != nil {
+		t.Fatal(err)
+	}
+	note := mustGetNote(t, id)
+	if item := note.Todo[0]; item.Checked || item.Due != "2030-01-11" {
+		t.Errorf("the item should stay open, due a week later: %+v", item)
+	}
+	if len(note.Done) != 1 || note.Done[0].Repeat != "" || note.Done[0].Due != "2030-01-04" {
+		t.Errorf("a checked copy without the rule should log the completion: %+v", note.Done)
+	}
+	if note.Done[0].Handle == note.Todo[0].Handle {
+		t.Error("the logged copy should get a handle of its own")
+	}
+}
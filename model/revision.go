@@ -0,0 +1,121 @@
+package jot
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+/* A previous version of a note as it looked in the editor, kept when the
+ * note is edited. Time is when the revision was replaced. */
+type Revision struct {
+	Time int64  `json:"time"`
+	Text string `json:"text"`
+}
+
+/* One line of a diff. Op is '+' for an added line, '-' for a removed line
+ * and ' ' for a line both sides share. */
+type DiffLine struct {
+	Op   byte
+	Text string
+}
+
+/* Returns the revisions of the note with id, oldest first. */
+func GetRevisions(id string) ([]Revision, error) {
+	note, err := GetNoteById(id)
+	return note.Revisions, err
+}
+
+/* Returns the text of revision rev of the note with id. rev equal to the
+ * number of revisions is the current version of the note. */
+func GetRevisionText(id string, rev int) (string, error) {
+	note, err := GetNoteById(id)
+	switch {
+	case err != nil:
+		return "", err
+	case rev < 0 || rev > len(note.Revisions):
+		return "", revisionOutOfRange(rev, len(note.Revisions))
+	case rev == len(note.Revisions):
+		return noteToString(note), nil
+	default:
+		return note.Revisions[rev].Text, nil
+	}
+}
+
+/* Given an id and a revision, overwrite the note with that revision. The
+ * current version becomes a new revision, so a revert can be reverted. */
+func RevertNote(id string, rev int) error {
+	end, err := begin()
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	note, err := GetNoteById(id)
+	if err != nil {
+		return err
+	}
+	if rev < 0 || rev >= len(note.Revisions) {
+		return revisionOutOfRange(rev, len(note.Revisions)-1)
+	}
+	return editNote("revert", quote("revision "+strconv.Itoa(rev)), id, noteToString(note), note.Revisions[rev].Text)
+}
+
+func revisionOutOfRange(rev, last int) error {
+	return fmt.Errorf("%w: revision %d, revisions go from 0 to %d", ErrItemOutOfRange, rev, last)
+}
+
+/* Adds the current version of note to its revisions before it is replaced
+ * by newNote. */
+func keepRevision(note Note, newNote *Note) {
+	newNote.Revisions = append(note.Revisions, Revision{
+		Time: time.Now().Unix(),
+		Text: noteToString(note),
+	})
+}
+
+/* Returns the line by line difference between a and b. */
+func Diff(a, b string) []DiffLine {
+	x, y := splitLines(a), splitLines(b)
+
+	// longest common subsequence of lines, lcs[i][j] for x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	diff := []DiffLine{}
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			diff = append(diff, DiffLine{' ', x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, DiffLine{'-', x[i]})
+			i++
+		default:
+			diff = append(diff, DiffLine{'+', y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		diff = append(diff, DiffLine{'-', x[i]})
+	}
+	for ; j < len(y); j++ {
+		diff = append(diff, DiffLine{'+', y[j]})
+	}
+	return diff
+}
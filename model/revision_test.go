@@ -0,0 +1,38 @@
+package jot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEditKeepsRevisions(t *testing.T) {
+	id := useNotes(t, "t\n - a\n")[0]
+	before := mustGetNote(t, id)
+	text := noteToString(before)
+
+	if err := EditNote(id, text, text); err != nil {
+		t.Fatal(err)
+	}
+	if note := mustGetNote(t, id); !reflect.DeepEqual(note, before) {
+		t.Errorf("an edit without changes should leave the note as it was: %+v", note)
+	}
+
+	if err := EditNote(id, text, "t\n - a\n - b\n"); err != nil {
+		t.Fatal(err)
+	}
+	note := mustGetNote(t, id)
+	if len(note.Revisions) != 1 || note.Revisions[0].Text != text {
+		t.Errorf("got revisions %+v, want the text before the edit", note.Revisions)
+	}
+	if note.Todo[0].Handle != before.Todo[0].Handle {
+		t.Error("a should keep its handle")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	got := Diff("a\nb\nc\n", "a\nc\nd\n")
+	want := []DiffLine{{' ', "a"}, {'-', "b"}, {' ', "c"}, {'+', "d"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
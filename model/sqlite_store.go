@@ -0,0 +1,438 @@
+package jot
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+/* A Store backed by an SQLite database. Notes and their checklist items live
+ * in separate tables, titles are indexed by trigram for substring search.
+ * Unlike the file stores nothing is cached, lookups go to the database. */
+type SQLiteStore struct {
+	path string
+	db   *sql.DB
+	conn *sql.Conn // holds the write transaction while locked
+}
+
+/* Anything queries can run on: the database or the locked connection. */
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS notes (
+	id    TEXT PRIMARY KEY,
+	seq   INTEGER NOT NULL,
+	title TEXT NOT NULL,
+	time  INTEGER NOT NULL,
+	doc   TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS notes_seq ON notes (seq);
+CREATE INDEX IF NOT EXISTS notes_title ON notes (title);
+CREATE TABLE IF NOT EXISTS items (
+	note_id  TEXT NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+	list     TEXT NOT NULL,
+	position INTEGER NOT NULL,
+	text     TEXT NOT NULL,
+	data     TEXT NOT NULL,
+	PRIMARY KEY (note_id, list, position)
+);
+DROP TABLE IF EXISTS notes_fts;
+CREATE VIRTUAL TABLE IF NOT EXISTS titles_fts USING fts5 (id UNINDEXED, title, tokenize = 'trigram');
+`
+
+// Names of the checklists in the items table, as in notes.json
+const (
+	todoList = "to-do"
+	doneList = "done"
+)
+
+var sqliteCtx = context.Background()
+
+/* Returns an SQLiteStore for the database file at path. Call Load before use. */
+func NewSQLiteStore(path string) *SQLiteStore {
+	return &SQLiteStore{path: path}
+}
+
+/* Returns the path of the database file. */
+func (s *SQLiteStore) Path() string {
+	return s.path
+}
+
+/* Opens the database, creating the schema if needed and upgrading notes
+ * written by an older version of jot. */
+func (s *SQLiteStore) Load() error {
+	if s.db == nil {
+		dsn := "file:" + (&url.URL{Path: s.path}).EscapedPath() +
+			"?_pragma=busy_timeout(10000)&_pragma=foreign_keys(1)"
+		db, err := sql.Open("sqlite", dsn)
+		if err != nil {
+			return err
+		}
+		s.db = db
+	}
+
+	q := s.querier()
+	if _, err := q.ExecContext(sqliteCtx, sqliteSchema); err != nil {
+		return err
+	}
+
+	var version, count, indexed int
+	if err := q.QueryRowContext(sqliteCtx, "PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if err := q.QueryRowContext(sqliteCtx, "SELECT COUNT(*) FROM notes").Scan(&count); err != nil {
+		return err
+	}
+	if err := q.QueryRowContext(sqliteCtx, "SELECT COUNT(*) FROM titles_fts").Scan(&indexed); err != nil {
+		return err
+	}
+	if indexed != count {
+		// the index is new, or was left behind by an older version of jot
+		err := s.transaction(func(q querier) error {
+			if _, err := q.ExecContext(sqliteCtx, "DELETE FROM titles_fts"); err != nil {
+				return err
+			}
+			_, err := q.ExecContext(sqliteCtx, "INSERT INTO titles_fts (id, title) SELECT id, title FROM notes")
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if count == 0 && version != FormatVersion {
+		// a new database
+		_, err := q.ExecContext(sqliteCtx, fmt.Sprintf("PRAGMA user_version = %d", FormatVersion))
+		return err
+	}
+	if version == FormatVersion {
+		return nil
+	}
+
+	// rewrite every note in the current format
+	return s.transaction(func(q querier) error {
+		notes, err := s.readNotes(q, version, "")
+		if err != nil {
+			return err
+		}
+		for _, note := range notes {
+			err = s.writeNote(q, note)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = q.ExecContext(sqliteCtx, fmt.Sprintf("PRAGMA user_version = %d", FormatVersion))
+		return err
+	})
+}
+
+func (s *SQLiteStore) Get(id string) (Note, bool, error) {
+	notes, err := s.readNotes(s.querier(), FormatVersion, id)
+	if err != nil || len(notes) == 0 {
+		return Note{}, false, err
+	}
+	return notes[0], true, nil
+}
+
+func (s *SQLiteStore) Put(note Note) error {
+	return s.transaction(func(q querier) error {
+		return s.writeNote(q, note)
+	})
+}
+
+func (s *SQLiteStore) Delete(id string) error {
+	return s.transaction(func(q querier) error {
+		_, err := q.ExecContext(sqliteCtx, "DELETE FROM notes WHERE id = ?", id)
+		if err != nil {
+			return err
+		}
+		_, err = q.ExecContext(sqliteCtx, "DELETE FROM titles_fts WHERE id = ?", id)
+		return err
+	})
+}
+
+/* Returns the ids of every note, including those in the trash. */
+func (s *SQLiteStore) Ids() ([]string, error) {
+	rows, err := s.querier().QueryContext(sqliteCtx, "SELECT id FROM notes ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+/* Returns the ids of the notes titled title, oldest first. */
+func (s *SQLiteStore) IdsByTitle(title string) ([]string, error) {
+	rows, err := s.querier().QueryContext(sqliteCtx, "SELECT id FROM notes WHERE title = ? ORDER BY seq", title)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (s *SQLiteStore) List() ([]Note, error) {
+	return s.readNotes(s.querier(), FormatVersion, "")
+}
+
+/* Escapes the wildcards of LIKE patterns. */
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+/* Returns the notes with any of the keywords in their title, ignoring case,
+ * as SearchNotes does. An empty keyword matches every note. */
+func (s *SQLiteStore) Search(keywords []string) ([]Note, error) {
+	if len(keywords) == 0 {
+		return []Note{}, nil
+	}
+	// the trigram index serves LIKE, keywords shorter than three characters
+	// make it scan the titles instead
+	conditions, args := []string{}, []interface{}{}
+	for _, keyword := range keywords {
+		conditions = append(conditions, `f.title LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likeEscaper.Replace(keyword)+"%")
+	}
+
+	q := s.querier()
+	rows, err := q.QueryContext(sqliteCtx,
+		`SELECT n.id FROM titles_fts f JOIN notes n ON n.id = f.id
+		 WHERE `+strings.Join(conditions, " OR ")+` ORDER BY n.seq`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	notes := []Note{}
+	for _, id := range ids {
+		note, found, err := s.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			notes = append(notes, note)
+		}
+	}
+	return notes, rows.Err()
+}
+
+/* Starts a write transaction that lasts until Unlock. Other processes wait
+ * for it to finish. */
+func (s *SQLiteStore) Lock() error {
+	if s.conn != nil {
+		return nil
+	}
+	if s.db == nil {
+		if err := s.Load(); err != nil {
+			return err
+		}
+	}
+	conn, err := s.db.Conn(sqliteCtx)
+	if err != nil {
+		return err
+	}
+	if _, err = conn.ExecContext(sqliteCtx, "BEGIN IMMEDIATE"); err != nil {
+		conn.Close()
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+/* Commits the transaction started by Lock. */
+func (s *SQLiteStore) Unlock() error {
+	if s.conn == nil {
+		return nil
+	}
+	_, err := s.conn.ExecContext(sqliteCtx, "COMMIT")
+	closeErr := s.conn.Close()
+	s.conn = nil
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+func (s *SQLiteStore) querier() querier {
+	if s.conn != nil {
+		return s.conn
+	}
+	return s.db
+}
+
+/* Runs fn in a transaction, or inside the one held by Lock. */
+func (s *SQLiteStore) transaction(fn func(q querier) error) error {
+	if s.conn != nil {
+		return fn(s.conn)
+	}
+	tx, err := s.db.BeginTx(sqliteCtx, nil)
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+/* Reads the note with id, or every note if id is empty, from rows written in
+ * format version. */
+func (s *SQLiteStore) readNotes(q querier, version int, id string) ([]Note, error) {
+	where, args := "", []interface{}{}
+	if id != "" {
+		where, args = " WHERE id = ?", []interface{}{id}
+	}
+
+	// notes
+	rows, err := q.QueryContext(sqliteCtx, "SELECT id, doc FROM notes"+where+" ORDER BY seq", args...)
+	if err != nil {
+		return nil, err
+	}
+	rawNotes := []interface{}{}
+	byId := map[string]map[string]interface{}{}
+	for rows.Next() {
+		var noteId, doc string
+		if err = rows.Scan(&noteId, &doc); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		rawNote := map[string]interface{}{}
+		if err = json.Unmarshal([]byte(doc), &rawNote); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("note %s: %v", noteId, err)
+		}
+		rawNote[todoList] = []interface{}{}
+		rawNote[doneList] = []interface{}{}
+		rawNotes = append(rawNotes, rawNote)
+		byId[noteId] = rawNote
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// items
+	where = strings.Replace(where, "id", "note_id", 1)
+	rows, err = q.QueryContext(sqliteCtx, "SELECT note_id, list, data FROM items"+where+" ORDER BY note_id, list, position", args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var noteId, list, data string
+		if err = rows.Scan(&noteId, &list, &data); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		var item interface{}
+		if err = json.Unmarshal([]byte(data), &item); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("note %s: %v", noteId, err)
+		}
+		if rawNote, ok := byId[noteId]; ok {
+			rawNote[list] = append(rawNote[list].([]interface{}), item)
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// let the migrations bring old rows up to date
+	bytes, err := json.Marshal(map[string]interface{}{"version": version, "notes": rawNotes})
+	if err != nil {
+		return nil, err
+	}
+	notes, _, err := decodeNotes(bytes)
+	if err != nil {
+		return nil, err
+	}
+	assignAllHandles(notes.Notes)
+	return cloneNotes(notes.Notes), nil
+}
+
+/* Inserts or replaces note along with its items and search index entry. */
+func (s *SQLiteStore) writeNote(q querier, note Note) error {
+	bytes, err := json.Marshal(note)
+	if err != nil {
+		return err
+	}
+	doc := map[string]interface{}{}
+	if err = json.Unmarshal(bytes, &doc); err != nil {
+		return err
+	}
+	delete(doc, todoList)
+	delete(doc, doneList)
+	if bytes, err = json.Marshal(doc); err != nil {
+		return err
+	}
+
+	// keep the position of existing notes, append new ones
+	_, err = q.ExecContext(sqliteCtx,
+		`INSERT INTO notes (id, seq, title, time, doc)
+		 VALUES (?, COALESCE((SELECT seq FROM notes WHERE id = ?), (SELECT IFNULL(MAX(seq), 0) + 1 FROM notes)), ?, ?, ?)
+		 ON CONFLICT (id) DO UPDATE SET title = excluded.title, time = excluded.time, doc = excluded.doc`,
+		note.Id, note.Id, note.Title, note.Created, string(bytes))
+	if err != nil {
+		return err
+	}
+
+	_, err = q.ExecContext(sqliteCtx, "DELETE FROM items WHERE note_id = ?", note.Id)
+	if err != nil {
+		return err
+	}
+	lists := map[string][]Item{todoList: note.Todo, doneList: note.Done}
+	for _, list := range []string{todoList, doneList} {
+		for position, item := range lists[list] {
+			data, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			_, err = q.ExecContext(sqliteCtx,
+				"INSERT INTO items (note_id, list, position, text, data) VALUES (?, ?, ?, ?, ?)",
+				note.Id, list, position, item.Text, string(data))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = q.ExecContext(sqliteCtx, "DELETE FROM titles_fts WHERE id = ?", note.Id)
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(sqliteCtx, "INSERT INTO titles_fts (id, title) VALUES (?, ?)", note.Id, note.Title)
+	return err
+}
@@ -0,0 +1,130 @@
+package jot
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+/* Returns a loaded SQLiteStore in a new temporary directory. */
+func newSQLiteStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	s := NewSQLiteStore(filepath.Join(t.TempDir(), "notes.db"))
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.db.Close() })
+	return s
+}
+
+func TestSQLiteCopyRoundTrip(t *testing.T) {
+	useNotes(t, "Work plan #work\nnotes\n - a !1 @due(2026-11-01)\n   - a1\n X b", "groceries\n - milk", "Work plan")
+	s := newSQLiteStore(t)
+	n, err := CopyNotes(s, store)
+	if err != nil || n != 3 {
+		t.Fatalf("CopyNotes: got %d, %v, want 3 notes", n, err)
+	}
+
+	// a fresh store on the same file reads the same notes, in the same order
+	reopened := NewSQLiteStore(s.Path())
+	if err = reopened.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.db.Close()
+	want, _ := store.List()
+	got, err := reopened.List()
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("List: got %+v, %v, want %+v", got, err, want)
+	}
+}
+
+func TestSQLiteTitlesAndSearch(t *testing.T) {
+	ids := useNotes(t, "Work plan", "groceries", "Work plan", "100% done")
+	s := newSQLiteStore(t)
+	if _, err := CopyNotes(s, store); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := s.IdsByTitle("Work plan")
+	if err != nil || !reflect.DeepEqual(found, []string{ids[0], ids[2]}) {
+		t.Errorf("IdsByTitle: got %v, %v, want both work plans", found, err)
+	}
+
+	tests := []struct {
+		keywords []string
+		want     []string
+	}{
+		{[]string{""}, ids},
+		{[]string{"ork"}, []string{ids[0], ids[2]}},
+		{[]string{"WORK"}, []string{ids[0], ids[2]}},
+		{[]string{"gr", "plan"}, ids[:3]},
+		{[]string{"%"}, ids[3:]},
+		{[]string{"_"}, nil},
+		{[]string{"nothing"}, nil},
+	}
+	for _, test := range tests {
+		notes, err := s.Search(test.keywords)
+		got := []string{}
+		for _, note := range notes {
+			got = append(got, note.Id)
+		}
+		if err != nil || len(got) != len(test.want) || (len(got) > 0 && !reflect.DeepEqual(got, test.want)) {
+			t.Errorf("Search(%q): got %v, %v, want %v", test.keywords, got, err, test.want)
+		}
+	}
+}
+
+func TestSQLiteLockedChanges(t *testing.T) {
+	useNotes(t, "one")
+	s := newSQLiteStore(t)
+	if _, err := CopyNotes(s, store); err != nil {
+		t.Fatal(err)
+	}
+	note, _ := store.List()
+
+	if err := s.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	note[0].Title = "renamed"
+	if err := s.Put(note[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := s.IdsByTitle("renamed"); len(found) != 1 {
+		t.Error("a change made while locked should be kept once unlocked")
+	}
+	if notes, _ := s.Search([]string{"renamed"}); len(notes) != 1 {
+		t.Error("the title index should follow the new title")
+	}
+}
+
+func TestSQLiteLoadUpgrades(t *testing.T) {
+	useNotes(t, "Work plan\n - a", "groceries")
+	s := newSQLiteStore(t)
+	if _, err := CopyNotes(s, store); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := s.List()
+
+	// as left by an older version of jot: an older format and no title index
+	_, err := s.db.Exec("DROP TABLE titles_fts; PRAGMA user_version = " + fmt.Sprint(FormatVersion-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	var version int
+	if err = s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil || version != FormatVersion {
+		t.Errorf("got format version %d, %v, want %d", version, err, FormatVersion)
+	}
+	if got, err := s.List(); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("List: got %+v, %v, want %+v", got, err, want)
+	}
+	if notes, _ := s.Search([]string{"plan"}); len(notes) != 1 {
+		t.Errorf("the title index should be rebuilt, search found %d notes", len(notes))
+	}
+}
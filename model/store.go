@@ -0,0 +1,244 @@
+package jot
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+)
+
+/* A Store keeps notes somewhere. The rest of the package only reads and
+ * mutates notes through the active store, so jot can be embedded with any
+ * backend (or an in-memory one for tests). */
+type Store interface {
+	// Load (re)reads every note from the backing storage.
+	Load() error
+	// Get returns a copy of the note with the given id, found is false if
+	// there is none.
+	Get(id string) (note Note, found bool, err error)
+	// Put records the note, replacing any note with the same id.
+	Put(note Note) error
+	// Delete removes the note with the given id, if there is one.
+	Delete(id string) error
+	// List returns copies of all notes, oldest first.
+	List() ([]Note, error)
+}
+
+/* A Store that can be locked against other processes. While locked, a
+ * Load followed by mutations can not interleave with another jot process. */
+type Locker interface {
+	Lock() error
+	Unlock() error
+}
+
+/* A Store that can search note titles without scanning every note. */
+type Searcher interface {
+	Search(keywords []string) ([]Note, error)
+}
+
+/* A Store that can look notes up by title without scanning every note. */
+type TitleIndex interface {
+	IdsByTitle(title string) ([]string, error)
+}
+
+/* A Store that can list note ids without reading every note. */
+type IdIndex interface {
+	Ids() ([]string, error)
+}
+
+var store Store
+
+/* The file or directory the store keeps its notes in, "" if it was set with
+ * SetStore. */
+var storePath string
+
+/* Replace the store used by the package. The journal of a store set this
+ * way only lives in memory. */
+func SetStore(s Store) {
+	store = s
+	storePath = ""
+	journalPath = ""
+	journal = Journal{}
+}
+
+/* Returns the store used by the package. */
+func GetStore() Store {
+	return store
+}
+
+// Names of the storage backends that can be chosen in settings
+const (
+	BackendJSON     = "json"
+	BackendMarkdown = "markdown"
+	BackendSQLite   = "sqlite"
+)
+
+/* Returns a store of the named backend keeping its files in dataDir.
+ * The store still needs to be loaded. */
+func NewStore(backend, dataDir string) (Store, error) {
+	switch backend {
+	case BackendJSON, "":
+		return NewJSONStore(StoreLocation(backend, dataDir)), nil
+	case BackendMarkdown:
+		return NewMarkdownStore(StoreLocation(backend, dataDir)), nil
+	case BackendSQLite:
+		return NewSQLiteStore(StoreLocation(backend, dataDir)), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend: '%s'", backend)
+	}
+}
+
+/* Returns the file or directory the named backend keeps its notes in. */
+func StoreLocation(backend, dataDir string) string {
+	switch backend {
+	case BackendMarkdown:
+		return filepath.Join(dataDir, "notes")
+	case BackendSQLite:
+		return filepath.Join(dataDir, "notes.db")
+	default:
+		return filepath.Join(dataDir, "notes.json")
+	}
+}
+
+/* Copies every note from src into dst, which must not hold any notes yet,
+ * then checks that dst reads the notes back unchanged. Both stores must
+ * already be loaded. Returns the number of notes copied. Failures of either
+ * store match ErrStorage. */
+func CopyNotes(dst, src Store) (int, error) {
+	existing, err := dst.List()
+	if err != nil {
+		return 0, storageError(err)
+	}
+	if len(existing) != 0 {
+		return 0, fmt.Errorf("the destination already holds %d notes", len(existing))
+	}
+
+	notes, err := src.List()
+	if err != nil {
+		return 0, storageError(err)
+	}
+	for _, note := range notes {
+		err := dst.Put(note)
+		if err != nil {
+			return 0, storageError(err)
+		}
+	}
+
+	// read everything back to make sure nothing was lost on the way
+	err = dst.Load()
+	if err != nil {
+		return 0, storageError(err)
+	}
+	for _, note := range notes {
+		copied, found, err := dst.Get(note.Id)
+		if err != nil {
+			return 0, storageError(err)
+		}
+		if !found || !reflect.DeepEqual(copied, note) {
+			return 0, storageError(fmt.Errorf("note %s did not survive the copy", note.Id))
+		}
+	}
+	return len(notes), nil
+}
+
+/* A Store that only lives in memory. */
+type MemoryStore struct {
+	notes []Note
+}
+
+/* Returns a MemoryStore seeded with the given notes. */
+func NewMemoryStore(notes ...Note) *MemoryStore {
+	s := &MemoryStore{}
+	for _, note := range notes {
+		s.notes = append(s.notes, cloneNote(note))
+	}
+	return s
+}
+
+/* Nothing to load, the notes are already in memory. */
+func (s *MemoryStore) Load() error {
+	return nil
+}
+
+func (s *MemoryStore) Get(id string) (Note, bool, error) {
+	note, found := findNote(s.notes, id)
+	return note, found, nil
+}
+
+func (s *MemoryStore) Put(note Note) error {
+	s.notes = putNote(s.notes, note)
+	return nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.notes = deleteNote(s.notes, id)
+	return nil
+}
+
+func (s *MemoryStore) List() ([]Note, error) {
+	return cloneNotes(s.notes), nil
+}
+
+// Helpers shared by the slice backed stores
+
+/* Returns a copy of the note with id from notes. */
+func findNote(notes []Note, id string) (note Note, found bool) {
+	for i := 0; i < len(notes); i++ {
+		if notes[i].Id == id {
+			return cloneNote(notes[i]), true
+		}
+	}
+	return
+}
+
+/* Replace the note with the same id as note, or append it if there is none. */
+func putNote(notes []Note, note Note) []Note {
+	note = cloneNote(note)
+	for i := 0; i < len(notes); i++ {
+		if notes[i].Id == note.Id {
+			notes[i] = note
+			return notes
+		}
+	}
+	return append(notes, note)
+}
+
+/* Remove the note with id from notes. */
+func deleteNote(notes []Note, id string) []Note {
+	for i := 0; i < len(notes); i++ {
+		if notes[i].Id == id {
+			return append(notes[:i], notes[i+1:]...)
+		}
+	}
+	return notes
+}
+
+/* Deep copy a note so callers can not mutate a store's slices. */
+func cloneNote(note Note) Note {
+	note.Lines = append([]string{}, note.Lines...)
+	note.Tags = append([]string(nil), note.Tags...)
+	note.Todo = append([]Item{}, cloneItems(note.Todo)...)
+	note.Done = append([]Item{}, cloneItems(note.Done)...)
+	note.Revisions = append([]Revision{}, note.Revisions...)
+	return note
+}
+
+/* Deep copies items, sub-items included. nil stays nil. */
+func cloneItems(items []Item) []Item {
+	if items == nil {
+		return nil
+	}
+	cloned := make([]Item, len(items))
+	for i, item := range items {
+		cloned[i] = item
+		cloned[i].Children = cloneItems(item.Children)
+	}
+	return cloned
+}
+
+func cloneNotes(notes []Note) []Note {
+	clones := make([]Note, len(notes))
+	for i := range notes {
+		clones[i] = cloneNote(notes[i])
+	}
+	return clones
+}
@@ -0,0 +1,162 @@
+package jot
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+/* Makes the package use a new MemoryStore holding a note for each of texts,
+ * made as by NewNote. Returns the ids of the notes. */
+func useNotes(t *testing.T, texts ...string) []string {
+	t.Helper()
+	SetStore(NewMemoryStore())
+	ShowArchived(false)
+	ids := []string{}
+	for _, text := range texts {
+		id, err := NewNote(text, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+/* Returns the note with id, failing the test if there is none. */
+func mustGetNote(t *testing.T, id string) Note {
+	t.Helper()
+	note, err := GetNoteById(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return note
+}
+
+/* Returns the texts of items, separated by spaces. */
+func texts(items []Item) string {
+	s := []string{}
+	for _, item := range items {
+		s = append(s, item.Text)
+	}
+	return strings.Join(s, " ")
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	one, two := parseNote("one\n - a"), parseNote("two")
+	one.Id, two.Id = "bngre9ku76li6v1ts97g", "bngrel4u76li6v1ts98g"
+	for _, note := range []Note{one, two} {
+		if err := s.Put(note); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, found, err := s.Get(one.Id)
+	if err != nil || !found || texts(got.Todo) != "a" {
+		t.Fatalf("Get: got %+v, %v, %v", got, found, err)
+	}
+	// the store hands out copies
+	got.Todo[0].Text = "changed"
+	if got, _, _ = s.Get(one.Id); got.Todo[0].Text != "a" {
+		t.Error("changing a note from Get changed the note in the store")
+	}
+
+	one.Title = "first"
+	if err = s.Put(one); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Delete(two.Id); err != nil {
+		t.Fatal(err)
+	}
+	notes, err := s.List()
+	if err != nil || len(notes) != 1 || notes[0].Title != "first" {
+		t.Errorf("List: got %+v, %v, want only the renamed first note", notes, err)
+	}
+	if _, found, _ = s.Get(two.Id); found {
+		t.Error("a deleted note should not be found")
+	}
+}
+
+func TestCopyNotes(t *testing.T) {
+	useNotes(t, "one\n - a", "two\n X b")
+	dst := NewMemoryStore()
+	n, err := CopyNotes(dst, store)
+	if err != nil || n != 2 {
+		t.Fatalf("CopyNotes: got %d, %v, want 2 notes", n, err)
+	}
+	if _, err = CopyNotes(dst, store); err == nil {
+		t.Error("copying into a store that holds notes should fail")
+	}
+	if n, err = CopyNotes(NewMemoryStore(), &unreadableStore{}); err == nil {
+		t.Errorf("copying from a store that can not be read copied %d notes", n)
+	}
+}
+
+var errRead = errors.New("disk on fire")
+
+/* A Store that can not read its notes. */
+type unreadableStore struct {
+	MemoryStore
+}
+
+func (s *unreadableStore) Get(id string) (Note, bool, error) {
+	return Note{}, false, errRead
+}
+
+func (s *unreadableStore) List() ([]Note, error) {
+	return nil, errRead
+}
+
+func TestReadErrorsAreStorageErrors(t *testing.T) {
+	SetStore(&unreadableStore{})
+
+	_, err := GetNoteById("bngre9ku76li6v1ts97g")
+	if !errors.Is(err, ErrStorage) || errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("GetNoteById: got %v, want a storage error", err)
+	}
+	_, err = ResolveId("bngre9ku")
+	if !errors.Is(err, ErrStorage) {
+		t.Errorf("ResolveId: got %v, want a storage error", err)
+	}
+	_, err = GetNotes()
+	if !errors.Is(err, ErrStorage) {
+		t.Errorf("GetNotes: got %v, want a storage error", err)
+	}
+	_, err = GetIdFromTitle("jot")
+	if !errors.Is(err, ErrStorage) {
+		t.Errorf("GetIdFromTitle: got %v, want a storage error", err)
+	}
+}
+
+func TestMigrateStorageBackAndForth(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Bootstrap(StoreLocation(BackendJSON, dir)); err != nil {
+		t.Fatal(err)
+	}
+	if err := Open(BackendJSON, dir); err != nil {
+		t.Fatal(err)
+	}
+	notes, _ := GetNotes()
+
+	if _, _, err := MigrateStorage(BackendJSON, dir); err == nil || errors.Is(err, ErrStorage) {
+		t.Errorf("migrating to the store in use: got %v, want a plain error", err)
+	}
+	n, backup, err := MigrateStorage(BackendMarkdown, dir)
+	if err != nil || n != len(notes.Notes) || backup != "" {
+		t.Fatalf("migrating to markdown: got %d, %q, %v", n, backup, err)
+	}
+
+	// and back again, over the notes.json that is still there
+	if err = Open(BackendMarkdown, dir); err != nil {
+		t.Fatal(err)
+	}
+	n, backup, err = MigrateStorage(BackendJSON, dir)
+	if err != nil || n != len(notes.Notes) {
+		t.Fatalf("migrating back to json: got %d, %v", n, err)
+	}
+	if _, err = os.Stat(backup); err != nil {
+		t.Errorf("the old notes.json should be kept: %v", err)
+	}
+}
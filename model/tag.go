@@ -0,0 +1,192 @@
+package jot
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+/* A #tag: letters, digits, _, - and /, starting with a letter, digit or _
+ * and not made of digits alone, so #12 stays an issue number. It must start
+ * the text or follow a space, so C# is no tag. */
+var tagPattern = regexp.MustCompile(`(^|\s)#([\p{L}\p{N}_][\p{L}\p{N}_/-]*)`)
+
+var tagName = regexp.MustCompile(`^[\p{L}\p{N}_][\p{L}\p{N}_/-]*$`)
+
+var digits = regexp.MustCompile(`^[0-9]+$`)
+
+/* A tag and how many notes have it. */
+type TagCount struct {
+	Tag   string
+	Notes int
+}
+
+/* Returns the tags written in text, lower case, in order of appearance. */
+func tagsIn(text string) []string {
+	tags := []string{}
+	for _, match := range tagPattern.FindAllStringSubmatch(text, -1) {
+		if !digits.MatchString(match[2]) {
+			tags = append(tags, strings.ToLower(match[2]))
+		}
+	}
+	return tags
+}
+
+/* Returns the tags of the title, lines and items of note, sorted and without
+ * repeats. */
+func findTags(note Note) []string {
+	tags := tagsIn(note.Title)
+	for _, line := range note.Lines {
+		tags = append(tags, tagsIn(line)...)
+	}
+	collect := func(item *Item) {
+		tags = append(tags, tagsIn(item.Text)...)
+	}
+	walkItems(note.Todo, collect)
+	walkItems(note.Done, collect)
+	return uniqueTags(tags)
+}
+
+func uniqueTags(tags []string) []string {
+	sort.Strings(tags)
+	unique := []string{}
+	for i, tag := range tags {
+		if i == 0 || tag != tags[i-1] {
+			unique = append(unique, tag)
+		}
+	}
+	return unique
+}
+
+/* Returns whether note has tag. */
+func (note Note) HasTag(tag string) bool {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	for _, t := range note.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+/* Parses a tag given on its own, with or without the #. Tags are lower case. */
+func ParseTag(s string) (string, error) {
+	tag := strings.TrimPrefix(s, "#")
+	if !tagName.MatchString(tag) || digits.MatchString(tag) {
+		return "", fmt.Errorf("'%s' is not a tag, tags are made of letters, digits, _, - and /", s)
+	}
+	return strings.ToLower(tag), nil
+}
+
+/* Returns every tag of the notes that are not in the trash and how many
+ * notes have it, ordered by tag. */
+func GetTagCounts() ([]TagCount, error) {
+	notes, err := listNotes()
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, note := range live(notes) {
+		for _, tag := range note.Tags {
+			counts[tag]++
+		}
+	}
+	tags := []TagCount{}
+	for tag, n := range counts {
+		tags = append(tags, TagCount{tag, n})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Tag < tags[j].Tag
+	})
+	return tags, nil
+}
+
+/* Given the id of the note, tag it with add and untag it from remove.
+ * Added tags the note does not have yet are written on a line of tags at the
+ * end of the note. Removed tags are dropped from that line and lose their #
+ * everywhere else, so the words stay. */
+func TagNote(id string, add, remove []string) error {
+	changes := []string{}
+	for _, tag := range add {
+		changes = append(changes, "+"+tag)
+	}
+	for _, tag := range remove {
+		changes = append(changes, "-"+tag)
+	}
+	return updateNote("tag", id, func(note *Note) (string, error) {
+		for _, tag := range remove {
+			untag(note, tag)
+		}
+		for _, tag := range add {
+			if !note.HasTag(tag) {
+				addTagLine(note, tag)
+			}
+		}
+		return strings.Join(changes, " "), nil
+	})
+}
+
+func TagNoteByTitle(title string, add, remove []string) error {
+	return byTitle(title, func(id string) error {
+		return TagNote(id, add, remove)
+	})
+}
+
+/* Returns whether line holds nothing but tags. */
+func isTagLine(line string) bool {
+	fields := strings.Fields(line)
+	for _, field := range fields {
+		if len(tagsIn(field)) != 1 || len(field) != len(tagsIn(field)[0])+1 {
+			return false
+		}
+	}
+	return len(fields) > 0
+}
+
+/* Writes #tag on the line of tags at the end of note, starting one if the
+ * last line is not one. */
+func addTagLine(note *Note, tag string) {
+	last := len(note.Lines) - 1
+	if last >= 0 && isTagLine(note.Lines[last]) {
+		note.Lines[last] += " #" + tag
+		return
+	}
+	note.Lines = append(note.Lines, "#"+tag)
+}
+
+/* Removes tag from note, see TagNote. */
+func untag(note *Note, tag string) {
+	hashed := regexp.MustCompile(`(?i)(^|\s)#(` + regexp.QuoteMeta(tag) + `)(\s|$)`)
+	strip := func(text string) string {
+		// matches share spaces, so repeat until no match is left
+		for hashed.MatchString(text) {
+			text = hashed.ReplaceAllString(text, "$1$2$3")
+		}
+		return text
+	}
+
+	lines := []string{}
+	for _, line := range note.Lines {
+		if isTagLine(line) {
+			kept := []string{}
+			for _, field := range strings.Fields(line) {
+				if !strings.EqualFold(field, "#"+tag) {
+					kept = append(kept, field)
+				}
+			}
+			if len(kept) > 0 {
+				lines = append(lines, strings.Join(kept, " "))
+			}
+			continue
+		}
+		lines = append(lines, strip(line))
+	}
+	note.Lines = lines
+	note.Title = strip(note.Title)
+	retext := func(item *Item) {
+		item.Text = strip(item.Text)
+	}
+	walkItems(note.Todo, retext)
+	walkItems(note.Done, retext)
+}
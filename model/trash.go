@@ -0,0 +1,77 @@
+package jot
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+/* Returns the notes in the trash, most recently deleted first. */
+func GetTrash() (Notes, error) {
+	notes, err := listNotes()
+	if err != nil {
+		return Notes{}, err
+	}
+	trashed := []Note{}
+	for _, note := range notes {
+		if note.Deleted != 0 {
+			trashed = append(trashed, note)
+		}
+	}
+	sort.SliceStable(trashed, func(i, j int) bool {
+		return trashed[i].Deleted > trashed[j].Deleted
+	})
+	return Notes{Notes: trashed}, nil
+}
+
+/* Given an id, take the note with this id out of the trash and return its title */
+func RestoreNote(id string) (restoredTitle string, err error) {
+	end, err := begin()
+	if err != nil {
+		return "", err
+	}
+	defer end()
+
+	note, found, err := store.Get(id)
+	if err != nil {
+		return "", storageError(err)
+	}
+	if !found || note.Deleted == 0 {
+		return "", fmt.Errorf("%w in the trash with id: %s", ErrNoteNotFound, id)
+	}
+	note.Deleted = 0
+	return note.Title, writeNote("restore", "", note)
+}
+
+/* Permanently delete the notes that have been in the trash for longer than
+ * olderThan, or all of them if olderThan is 0, dropping them from the journal
+ * as well, so this can not be undone. Return how many were deleted. */
+func EmptyTrash(olderThan time.Duration) (int, error) {
+	end, err := begin()
+	if err != nil {
+		return 0, err
+	}
+	defer end()
+
+	trash, err := GetTrash()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-olderThan).Unix()
+	deleted := 0
+	for _, note := range trash.Notes {
+		if olderThan != 0 && note.Deleted > cutoff {
+			continue
+		}
+		err = store.Delete(note.Id)
+		if err != nil {
+			return deleted, storageError(err)
+		}
+		err = forgetNote(note.Id)
+		if err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
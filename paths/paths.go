@@ -0,0 +1,119 @@
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+/* Where jot keeps its files and how that was decided. */
+type Paths struct {
+	DataDir   string
+	ConfigDir string
+	Source    string
+}
+
+// Sources a Paths can be resolved from, in order of precedence.
+const (
+	SourceFlag   = "--data flag"
+	SourceEnv    = "JOT_HOME"
+	SourceXDG    = "XDG"
+	SourceLegacy = "legacy (next to the executable)"
+)
+
+/* Returns the path of notes.json. */
+func (p Paths) NotesFile() string {
+	return filepath.Join(p.DataDir, "notes.json")
+}
+
+/* Returns the path of settings.json. */
+func (p Paths) SettingsFile() string {
+	return filepath.Join(p.ConfigDir, "settings.json")
+}
+
+/* Resolve where the data and settings live. In order: dataFlag (the --data
+ * flag), the JOT_HOME environment variable, then $XDG_DATA_HOME/jot and
+ * $XDG_CONFIG_HOME/jot. If nothing exists in the XDG locations yet but an old
+ * install keeps its files in data/ next to the executable, that is used. */
+func Resolve(dataFlag string) (Paths, error) {
+	if dataFlag != "" {
+		dir, err := filepath.Abs(dataFlag)
+		if err != nil {
+			return Paths{}, err
+		}
+		return Paths{DataDir: dir, ConfigDir: dir, Source: SourceFlag}, nil
+	}
+
+	if home := os.Getenv("JOT_HOME"); home != "" {
+		dir, err := filepath.Abs(home)
+		if err != nil {
+			return Paths{}, err
+		}
+		return Paths{DataDir: dir, ConfigDir: dir, Source: SourceEnv}, nil
+	}
+
+	xdg, err := xdgPaths()
+	if err != nil {
+		return Paths{}, err
+	}
+	if exists(xdg.NotesFile()) || exists(xdg.SettingsFile()) {
+		return xdg, nil
+	}
+
+	legacy, err := legacyPaths()
+	if err == nil && exists(legacy.NotesFile()) {
+		return legacy, nil
+	}
+	return xdg, nil
+}
+
+/* Paths following the XDG base directory spec. */
+func xdgPaths() (Paths, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return Paths{}, err
+	}
+
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if runtime.GOOS == "windows" {
+		// windows has no ~/.local, keep both in the roaming app data folder
+		appData, err := os.UserConfigDir()
+		if err != nil {
+			return Paths{}, err
+		}
+		if dataHome == "" {
+			dataHome = appData
+		}
+		if configHome == "" {
+			configHome = appData
+		}
+	}
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+
+	return Paths{
+		DataDir:   filepath.Join(dataHome, "jot"),
+		ConfigDir: filepath.Join(configHome, "jot"),
+		Source:    SourceXDG,
+	}, nil
+}
+
+/* The data folder next to the executable used by older versions of jot. */
+func legacyPaths() (Paths, error) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return Paths{}, err
+	}
+	dir := filepath.Join(exePath, "../data/")
+	return Paths{DataDir: dir, ConfigDir: dir, Source: SourceLegacy}, nil
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
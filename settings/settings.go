@@ -1,9 +1,12 @@
 package settings
 
 import (
+	_ "embed"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 )
 
@@ -11,6 +14,9 @@ import (
 type Settings struct {
 	Style      Style      `json:"style"`
 	TextEditor TextEditor `json:"text-editor"`
+	Storage    Storage    `json:"storage"`
+	Listing    Listing    `json:"listing"`
+	Archive    Archive    `json:"archive"`
 }
 
 /* Style section of settings file */
@@ -36,6 +42,21 @@ type Style struct {
 	DoneBulletBackground string `json:"done-bullet-background"`
 	DoneItemColor        string `json:"done-item-color"`
 	DoneItemBackground   string `json:"done-item-background"`
+	OverdueColor         string `json:"overdue-color"`
+	OverdueBackground    string `json:"overdue-background"`
+	Priority1Color       string `json:"priority-1-color"`
+	Priority1Background  string `json:"priority-1-background"`
+	Priority2Color       string `json:"priority-2-color"`
+	Priority2Background  string `json:"priority-2-background"`
+	Priority3Color       string `json:"priority-3-color"`
+	Priority3Background  string `json:"priority-3-background"`
+	TagColor             string `json:"tag-color"`
+	TagBackground        string `json:"tag-background"`
+	LinkColor            string `json:"link-color"`
+	LinkBackground       string `json:"link-background"`
+	BrokenLinkColor      string `json:"broken-link-color"`
+	BrokenLinkBackground string `json:"broken-link-background"`
+	SortByPriority       bool   `json:"sort-by-priority"`
 }
 
 /* Settings regarding the text editor used with jot */
@@ -44,29 +65,71 @@ type TextEditor struct {
 	TextEditorArgs []string `json:"text-editor-args"`
 }
 
+/* Settings regarding where and how notes are stored */
+type Storage struct {
+	Backend string `json:"backend"`
+}
+
+/* Settings regarding which notes are listed */
+type Listing struct {
+	RecentNotes int `json:"recent-notes"` // headers shown by a bare ls
+}
+
+/* Settings regarding the archive */
+type Archive struct {
+	AutoArchiveDays int `json:"auto-archive-days"` // 0 never archives notes by itself
+}
+
 var settings Settings
 
-/* Setup settings */
-func init() {
+/* The settings written on first run. */
+//go:embed settings_default.json
+var defaultSettings []byte
 
-	// open and close settings
-	exePath, err := os.Executable()
+/* Creates a settings file at path from the defaults if there is none yet.
+ * Returns whether the file was created. */
+func Bootstrap(path string) (created bool, err error) {
+	if _, err = os.Stat(path); err == nil || !os.IsNotExist(err) {
+		return false, err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
 	if err != nil {
-		panic(err.Error())
+		return false, err
 	}
+	err = ioutil.WriteFile(path, defaultSettings, 0644)
+	return err == nil, err
+}
 
-	file, err := os.Open(filepath.Join(exePath, "../data/settings.json"))
+/* Load settings from the settings file at path. Settings missing from the
+ * file, e.g. ones added by a newer jot, keep their default. */
+func Load(path string) error {
+	file, err := os.Open(path)
 	if err != nil {
-		panic("The settings file is missing: jot/data/settings.json")
+		return fmt.Errorf("The settings file is missing: %s", path)
 	}
 	defer file.Close()
 
-	// load settings
+	// load settings over the defaults
+	settings = Settings{}
+	err = json.Unmarshal(defaultSettings, &settings)
+	if err != nil {
+		return err
+	}
 	bytes, _ := ioutil.ReadAll(file)
 	err = json.Unmarshal(bytes, &settings)
 	if err != nil {
-		panic("The settings file is corrupted: jot/data/settings.json")
+		return fmt.Errorf("The settings file is corrupted: %s", path)
 	}
+	return nil
+}
+
+/* Writes the current settings to path. */
+func Save(path string) error {
+	bytes, err := json.MarshalIndent(settings, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, bytes, 0644)
 }
 
 /* Returns the whole settings file */
@@ -83,3 +146,58 @@ func GetStyle() Style {
 func GetTextEditor() TextEditor {
 	return settings.TextEditor
 }
+
+/* Returns the storage settings */
+func GetStorage() Storage {
+	return settings.Storage
+}
+
+/* Returns the listing settings */
+func GetListing() Listing {
+	return settings.Listing
+}
+
+/* Returns the archive settings */
+func GetArchive() Archive {
+	return settings.Archive
+}
+
+/* Replaces the style settings */
+func SetStyle(style Style) {
+	settings.Style = style
+}
+
+/* Replaces the settings for the text editor used with jot */
+func SetTextEditor(textEditor TextEditor) {
+	settings.TextEditor = textEditor
+}
+
+/* Replaces the storage settings */
+func SetStorage(storage Storage) {
+	settings.Storage = storage
+}
+
+/* Returns the full path of the text editor to use. This is the prefered
+ * editor from settings or, if none is set, $VISUAL or $EDITOR. found is false
+ * if none of these can be located. */
+func FindTextEditor() (path string, found bool) {
+	candidates := []string{
+		settings.TextEditor.TextEditorPath,
+		os.Getenv("VISUAL"),
+		os.Getenv("EDITOR"),
+	}
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		editor, err := exec.LookPath(candidate)
+		if err == nil {
+			return editor, true
+		}
+		// a prefered editor that is set but missing should not be papered over
+		if candidate == settings.TextEditor.TextEditorPath {
+			return "", false
+		}
+	}
+	return "", false
+}